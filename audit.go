@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/doraemonkeys/menv/audit"
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/env"
+	"github.com/doraemonkeys/menv/journal"
+)
+
+// useAudit logs the writes of this run if $MENV_AUDIT_LOG is set.
+func useAudit() {
+	if filename := audit.Path(); filename != "" {
+		env.UseStore(audit.Open(filename).Record(env.CurrentStore(), *cmd.StoreSpec, os.Args[1:]))
+	}
+}
+
+// showAudit handles -audit [KEY]: it lists the audit log, filtered with
+// -scope, -since and -until.
+func showAudit(args []string) error {
+	filename := audit.Path()
+	if filename == "" {
+		return errors.New("the audit log is off, set MENV_AUDIT_LOG to the log file to turn it on")
+	}
+
+	var f audit.Filter
+	switch len(args) {
+	case 0:
+	case 1:
+		f.Key = args[0]
+	default:
+		return fmt.Errorf("unexpected arguments: %v", args)
+	}
+	switch *cmd.AuditScope {
+	case "", env.ScopeUser.String(), env.ScopeSystem.String():
+		f.Scope = *cmd.AuditScope
+	default:
+		return fmt.Errorf("invalid scope %q, use user or system", *cmd.AuditScope)
+	}
+	now := time.Now()
+	var err error
+	if *cmd.Since != "" {
+		if f.Since, err = journal.ParseTime(*cmd.Since, now); err != nil {
+			return err
+		}
+	}
+	if *cmd.Until != "" {
+		if f.Until, err = journal.ParseUntil(*cmd.Until, now); err != nil {
+			return err
+		}
+	}
+
+	records, err := audit.Open(filename).Query(f)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		color.Info("No matching changes in %s", filename)
+		return nil
+	}
+	for _, r := range records {
+		change := r.Key
+		switch {
+		case r.Action == "delete":
+			change = "delete " + r.Key + " (was " + value(r.Old) + ")"
+		case r.Old != nil:
+			change += ": " + value(r.Old) + " -> " + value(r.New)
+		default:
+			change += "=" + value(r.New)
+		}
+		result := color.Sprintf(color.Green, "%s", r.Result)
+		if r.Result != "ok" {
+			result = color.Sprintf(color.Red, "%s", r.Result)
+		}
+		fmt.Printf("%s  %s@%s  %-6s  %s  %s  %s\n",
+			color.Sprintf(color.Cyan, "%s", r.Time.Local().Format(time.DateTime)),
+			r.User, r.Host, r.Scope, change, result,
+			color.Sprintf(color.Blue, "(%s)", r.Command))
+	}
+	return nil
+}
+
+func value(v *string) string {
+	if v == nil {
+		return "<unset>"
+	}
+	return *v
+}
@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/doraemonkeys/menv/env"
+)
+
+// failingStore fails every write.
+type failingStore struct {
+	env.Store
+}
+
+func (failingStore) Set(env.Scope, env.EnvVar) error { return errors.New("access denied") }
+
+func TestStore_Records(t *testing.T) {
+	l := Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	base := env.NewMemoryStore()
+	_ = base.Set(env.ScopeSystem, env.EnvVar{Key: "JAVA_HOME", Value: "jdk17"})
+
+	s := l.Record(base, "memory", []string{"-sys", "JAVA_HOME", "jdk21"})
+	if err := s.Set(env.ScopeSystem, env.EnvVar{Key: "JAVA_HOME", Value: "jdk21"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(env.ScopeUser, env.EnvVar{Key: "GITHUB_TOKEN", Value: "ghp_x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(env.ScopeSystem, "JAVA_HOME"); err != nil {
+		t.Fatal(err)
+	}
+	failing := l.Record(failingStore{base}, "memory", []string{"A", "1"})
+	if err := failing.Set(env.ScopeUser, env.EnvVar{Key: "A", Value: "1"}); err == nil {
+		t.Fatal("Set() through failing store expected error")
+	}
+
+	records, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("Query() = %d records, want 4", len(records))
+	}
+
+	r := records[0]
+	if r.Command != "menv -sys JAVA_HOME jdk21" || r.Scope != "system" || r.Action != "set" ||
+		*r.Old != "jdk17" || *r.New != "jdk21" || r.Result != "ok" || r.User == "" {
+		t.Errorf("records[0] = %+v", r)
+	}
+	if r := records[1]; r.Old != nil || *r.New != Redacted {
+		t.Errorf("records[1] = %+v, want a created, redacted value", r)
+	}
+	if r := records[2]; r.Action != "delete" || *r.Old != "jdk21" || r.New != nil {
+		t.Errorf("records[2] = %+v, want a deletion", r)
+	}
+	if r := records[3]; r.Result != "access denied" {
+		t.Errorf("records[3].Result = %q, want the error", r.Result)
+	}
+}
+
+func TestLog_Query(t *testing.T) {
+	l := Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 12, 0, 0, 0, time.UTC) }
+	for _, r := range []Record{
+		{Time: day(1), Scope: "user", Key: "JAVA_HOME"},
+		{Time: day(2), Scope: "system", Key: "Path"},
+		{Time: day(3), Scope: "system", Key: "JAVA_HOME"},
+	} {
+		if err := l.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{name: "all", want: 3},
+		{name: "key", filter: Filter{Key: "java_home"}, want: 2},
+		{name: "scope", filter: Filter{Scope: "system"}, want: 2},
+		{name: "since", filter: Filter{Since: day(2)}, want: 2},
+		{name: "until", filter: Filter{Until: day(2)}, want: 2},
+		{name: "combined", filter: Filter{Key: "JAVA_HOME", Scope: "system", Since: day(2), Until: day(3)}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("Query() = %d records, want %d", len(got), tt.want)
+			}
+		})
+	}
+
+	if got, err := Open(filepath.Join(t.TempDir(), "missing.jsonl")).Query(Filter{}); err != nil || got != nil {
+		t.Errorf("Query() of missing log = %v, %v, want none", got, err)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		key, value, want string
+	}{
+		{key: "JAVA_HOME", value: `C:\jdk`, want: `C:\jdk`},
+		{key: "DB_PASSWORD", value: "hunter2", want: Redacted},
+		{key: "npm_token", value: "x", want: Redacted},
+		{key: "AWS_SECRET_ACCESS_KEY", value: "x", want: Redacted},
+		{key: "OPENAI_API_KEY", value: "x", want: Redacted},
+		{key: "DB_PASSWORD", value: "", want: ""},
+		{key: "DATABASE_URL", value: "postgres://bob:s3cret@db:5432/app", want: "postgres://bob:" + Redacted + "@db:5432/app"},
+		{key: "PROXY", value: "http://proxy:8080", want: "http://proxy:8080"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.key, tt.value); got != tt.want {
+			t.Errorf("Redact(%q, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRedactCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"-sys", "JAVA_HOME", `C:\jdk`}, want: `menv -sys JAVA_HOME C:\jdk`},
+		{args: []string{"GITHUB_TOKEN", "ghp_x"}, want: "menv GITHUB_TOKEN " + Redacted},
+		{args: []string{"-d", "GITHUB_TOKEN"}, want: "menv -d GITHUB_TOKEN"},
+		{args: []string{"URL", "https://a:b@host"}, want: "menv URL https://a:" + Redacted + "@host"},
+	}
+	for _, tt := range tests {
+		if got := RedactCommand(tt.args); got != tt.want {
+			t.Errorf("RedactCommand(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
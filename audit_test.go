@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestShowAudit(t *testing.T) {
+	store := useStore(t)
+	if err := showAudit(nil); err == nil {
+		t.Error("showAudit() with the log off error = nil, want an error")
+	}
+
+	filename := filepath.Join(t.TempDir(), "audit.jsonl")
+	t.Setenv("MENV_AUDIT_LOG", filename)
+	for _, args := range [][]string{{"A", "1"}, {"A", "2"}} {
+		env.UseStore(store)
+		useAudit()
+		if err := execute(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env.UseStore(store)
+	useAudit()
+	setFlag(t, cmd.DelEnv, true)
+	if err := execute([]string{"A"}); err != nil {
+		t.Fatal(err)
+	}
+	env.UseStore(store)
+	if data, _ := os.ReadFile(filename); strings.Count(string(data), "\n") != 3 {
+		t.Errorf("audit log = %q, want 3 records", data)
+	}
+
+	if err := showAudit(nil); err != nil {
+		t.Fatalf("showAudit() error = %v", err)
+	}
+	setFlag(t, cmd.AuditScope, "user")
+	setFlag(t, cmd.Since, "1h")
+	setFlag(t, cmd.Until, "2999-12-31")
+	if err := showAudit([]string{"A"}); err != nil {
+		t.Fatalf("showAudit(A) error = %v", err)
+	}
+	if err := showAudit([]string{"A", "B"}); err == nil {
+		t.Error("showAudit(A, B) error = nil, want an error")
+	}
+
+	setFlag(t, cmd.Until, "someday")
+	if err := showAudit(nil); err == nil {
+		t.Error("showAudit() with an invalid -until error = nil, want an error")
+	}
+	setFlag(t, cmd.Since, "someday")
+	if err := showAudit(nil); err == nil {
+		t.Error("showAudit() with an invalid -since error = nil, want an error")
+	}
+	setFlag(t, cmd.AuditScope, "machine")
+	if err := showAudit(nil); err == nil {
+		t.Error("showAudit() with an invalid -scope error = nil, want an error")
+	}
+}
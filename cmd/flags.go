@@ -8,6 +8,14 @@ var (
 	SetSystem   = flag.Bool("sys", false, "set system env")
 	StartWith   = flag.String("startWith", "", "line start with")
 	AddPath     = flag.String("add", "", "add path")
+	Front       = flag.Bool("front", false, "with -add, insert at the front")
+	At          = flag.String("at", "", "with -add, insert as entry n (1-based)")
+	Before      = flag.String("before", "", "with -add, insert before this entry")
+	After       = flag.String("after", "", "with -add, insert after this entry")
+	Move        = flag.Bool("move", false, "with -add, move an existing entry to the position")
+	MovePath    = flag.String("mv", "", "move a PATH entry (path or 1-based index) to a position")
+	Up          = flag.Bool("up", false, "with -mv, move the entry one place up")
+	Down        = flag.Bool("down", false, "with -mv, move the entry one place down")
 	RemovePath  = flag.String("rm", "", "remove path from PATH")
 	CleanPath   = flag.Bool("clean", false, "clean PATH (dedupe + remove invalid)")
 	CheckPath   = flag.Bool("check", false, "check PATH for invalid directories")
@@ -17,7 +25,38 @@ var (
 	GetEnv      = flag.String("get", "", "get env var value")
 	ShowPath    = flag.Bool("path", false, "display PATH")
 	ExportPath  = flag.String("export", "", "export env vars to file (sh/bat/json)")
+	Typed       = flag.Bool("typed", false, "with -export to .json, write each var as {\"value\", \"type\"} to keep its value type")
 	BackupPath  = flag.String("backup", "", "backup env vars to JSON file")
 	RestorePath = flag.String("restore", "", "restore env vars from backup file")
+	RestoreMode = flag.String("mode", "merge", "with -restore: merge, replace (also delete vars not in the backup) or missing (only create unset vars)")
 	Search      = flag.String("search", "", "search env vars by keyword")
+	Effective   = flag.Bool("effective", false, "show merged system+user env (with -list, -get, -path)")
+	Expand      = flag.Bool("expand", false, "expand variable references (with -get)")
+	Graph       = flag.Bool("graph", false, "show variable reference graph")
+	Dot         = flag.Bool("dot", false, "print -graph as Graphviz DOT")
+	Cascade     = flag.Bool("cascade", false, "with -d, also remove dependent vars and PATH entries")
+	Inline      = flag.Bool("inline", false, "with -d, inline the value into dependent vars")
+	ListVar     = flag.String("var", "", "list variable for -add, -rm, -clean, -check, -path (default: PATH)")
+	Separator   = flag.String("sep", "", "entry separator of -var (default: , for NO_PROXY and GOPROXY, ; on Windows, : elsewhere)")
+	Rename      = flag.String("rename", "", "rename env var: -rename OLD NEW")
+	Force       = flag.Bool("force", false, "with -rename, -promote or -demote, overwrite an existing target")
+	RewriteRefs = flag.Bool("refs", false, "with -rename, rewrite references to the old name")
+	Promote     = flag.String("promote", "", "move a user env var (or PATH entry with -path) to system")
+	Demote      = flag.String("demote", "", "move a system env var (or PATH entry with -path) to user")
+	Keep        = flag.Bool("keep", false, "with -promote or -demote, copy instead of move")
+	Undo        = flag.Bool("undo", false, "undo the last change, or the last N with -undo N")
+	History     = flag.Bool("history", false, "list the changes recorded in the journal")
+	AsOf        = flag.String("as-of", "", "a point in time for -history KEY or -revert KEY (2006-01-02 15:04, 7d, 12h)")
+	Revert      = flag.String("revert", "", "restore a var to its value at the -as-of time")
+	Audit       = flag.Bool("audit", false, "list the audit log ($MENV_AUDIT_LOG), optionally for one KEY")
+	AuditScope  = flag.String("scope", "", "with -audit, only list changes to user or system vars")
+	Since       = flag.String("since", "", "with -audit, only list changes made at or after this time")
+	Until       = flag.String("until", "", "with -audit, only list changes made at or before this time (a date includes the whole day)")
+	DryRun      = flag.Bool("dry-run", false, "print the changes a command would make without writing them")
+	CheckOnly   = flag.Bool("check-only", false, "like -dry-run, but exit with an error if there are changes")
+	StoreSpec   = flag.String("store", "", "env store: registry, profile, environment.d, memory, or file:<path> (default: platform store)")
 )
+
+func init() {
+	flag.BoolVar(DryRun, "n", false, "shorthand for -dry-run")
+}
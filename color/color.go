@@ -1,11 +1,6 @@
 package color
 
-import (
-	"fmt"
-	"os"
-
-	"golang.org/x/sys/windows"
-)
+import "fmt"
 
 // ANSI color codes
 const (
@@ -32,15 +27,6 @@ func init() {
 	enableVirtualTerminal()
 }
 
-// enableVirtualTerminal enables ANSI escape sequences on Windows
-func enableVirtualTerminal() {
-	stdout := windows.Handle(os.Stdout.Fd())
-	var mode uint32
-	if err := windows.GetConsoleMode(stdout, &mode); err == nil {
-		_ = windows.SetConsoleMode(stdout, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
-	}
-}
-
 // Print functions with colors
 
 // func printColored(color, format string, args ...any) {
@@ -56,6 +42,23 @@ func Success(format string, args ...any) {
 	printColoredLn(Green, format, args...)
 }
 
+// doneMuted silences Done, see MuteDone.
+var doneMuted bool
+
+// MuteDone stops Done from printing, for dry runs that report what would
+// change once they are over instead of claiming each change was made.
+func MuteDone(mute bool) {
+	doneMuted = mute
+}
+
+// Done prints a green message reporting a change that was made, unless
+// muted by MuteDone.
+func Done(format string, args ...any) {
+	if !doneMuted {
+		printColoredLn(Green, format, args...)
+	}
+}
+
 // Error prints a red error message
 func Error(format string, args ...any) {
 	printColoredLn(Red, format, args...)
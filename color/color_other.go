@@ -0,0 +1,6 @@
+//go:build !windows
+
+package color
+
+// enableVirtualTerminal is a no-op: other terminals understand ANSI escapes natively.
+func enableVirtualTerminal() {}
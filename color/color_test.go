@@ -130,6 +130,28 @@ func TestSuccess(t *testing.T) {
 	}
 }
 
+func TestDone_Muted(t *testing.T) {
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	Done("set %s", "A")
+	MuteDone(true)
+	Done("set %s", "B")
+	MuteDone(false)
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	if !strings.Contains(output, "set A") || strings.Contains(output, "set B") {
+		t.Errorf("Done() output = %q, want only the message printed before MuteDone(true)", output)
+	}
+}
+
 func TestError(t *testing.T) {
 	old := os.Stdout
 	r, w, _ := os.Pipe()
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/env"
+)
+
+// deleteEnvVar deletes key after warning about the variables and PATH
+// entries that reference it. With dependents it asks whether to delete
+// anyway, cascade or inline, unless -cascade, -inline or -y decides.
+func deleteEnvVar(key string) error {
+	scope := env.ScopeOf(*cmd.SetSystem)
+	deps, err := env.FindDependents(scope, key)
+	if err != nil {
+		return err
+	}
+	if len(deps) == 0 {
+		return env.UnsetVar(scope, key)
+	}
+
+	color.Warning("%d variable(s) reference %s:", len(deps), key)
+	for _, d := range deps {
+		if d.Entries == nil {
+			fmt.Printf("  %s=%s  %s(%s)%s\n", d.Key, d.Value, color.Blue, d.Scope, color.Reset)
+			continue
+		}
+		for _, entry := range d.Entries {
+			fmt.Printf("  %s entry %s  %s(%s)%s\n", d.Key, entry, color.Blue, d.Scope, color.Reset)
+		}
+	}
+
+	action, err := deleteAction()
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "c":
+		return env.DeleteCascade(scope, key)
+	case "i":
+		return env.DeleteInline(scope, key)
+	case "d":
+		return env.UnsetVar(scope, key)
+	default:
+		color.Info("Cancelled")
+		return nil
+	}
+}
+
+// deleteAction returns d (delete only), c (cascade), i (inline) or "" to
+// cancel, from the flags or by asking. A dry run never asks: like -y, it
+// previews deleting the variable only.
+func deleteAction() (string, error) {
+	switch {
+	case *cmd.Cascade && *cmd.Inline:
+		return "", errors.New("-cascade and -inline cannot be combined")
+	case *cmd.Cascade:
+		return "c", nil
+	case *cmd.Inline:
+		return "i", nil
+	case *cmd.Yes, *cmd.DryRun, *cmd.CheckOnly:
+		return "d", nil
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("[d]elete anyway, [c]ascade remove dependents, [i]nline value into dependents, [N]o: ")
+	input, err := reader.ReadString('\n')
+	if err != nil {
+		return "", nil
+	}
+	switch input = strings.TrimSpace(strings.ToLower(input)); input {
+	case "d", "delete":
+		return "d", nil
+	case "c", "cascade":
+		return "c", nil
+	case "i", "inline":
+		return "i", nil
+	}
+	return "", nil
+}
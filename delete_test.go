@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestDeleteEnvVar_Dependents(t *testing.T) {
+	tests := []struct {
+		name    string
+		flag    *bool
+		input   string
+		java    string
+		maven   string
+		wantErr bool
+	}{
+		{name: "cancel", input: "\n", java: `C:\jdk`, maven: `%JAVA_HOME%\maven`},
+		{name: "no input", input: "", java: `C:\jdk`, maven: `%JAVA_HOME%\maven`},
+		{name: "delete anyway", input: "d\n", java: "<unset>", maven: `%JAVA_HOME%\maven`},
+		{name: "inline answer", input: "inline\n", java: "<unset>", maven: `C:\jdk\maven`},
+		{name: "-cascade", flag: cmd.Cascade, java: "<unset>", maven: "<unset>"},
+		{name: "-inline", flag: cmd.Inline, java: "<unset>", maven: `C:\jdk\maven`},
+		{name: "-y", flag: cmd.Yes, java: "<unset>", maven: `%JAVA_HOME%\maven`},
+		{name: "dry run never asks", flag: cmd.DryRun, java: "<unset>", maven: `%JAVA_HOME%\maven`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useStore(t,
+				env.EnvVar{Key: "JAVA_HOME", Value: `C:\jdk`, Type: env.TypeString},
+				env.EnvVar{Key: "MAVEN_HOME", Value: `%JAVA_HOME%\maven`, Type: env.TypeExpandString},
+			)
+			if tt.flag != nil {
+				setFlag(t, tt.flag, true)
+			}
+			useStdin(t, tt.input)
+
+			if err := deleteEnvVar("JAVA_HOME"); err != nil {
+				t.Fatalf("deleteEnvVar() error = %v", err)
+			}
+			if got := userValue(t, store, "JAVA_HOME"); got != tt.java {
+				t.Errorf("JAVA_HOME = %q, want %q", got, tt.java)
+			}
+			if got := userValue(t, store, "MAVEN_HOME"); got != tt.maven {
+				t.Errorf("MAVEN_HOME = %q, want %q", got, tt.maven)
+			}
+		})
+	}
+}
+
+func TestDeleteEnvVar_CascadeAndInline(t *testing.T) {
+	useStore(t,
+		env.EnvVar{Key: "JAVA_HOME", Value: `C:\jdk`},
+		env.EnvVar{Key: "Path", Value: `C:\bin;%JAVA_HOME%\bin`, Type: env.TypeExpandString},
+	)
+	setFlag(t, cmd.Cascade, true)
+	setFlag(t, cmd.Inline, true)
+
+	if err := deleteEnvVar("JAVA_HOME"); err == nil {
+		t.Error("deleteEnvVar() with -cascade and -inline error = nil, want an error")
+	}
+}
+
+func TestDeleteEnvVar_NoDependents(t *testing.T) {
+	store := useStore(t, env.EnvVar{Key: "A", Value: "1"})
+
+	if err := deleteEnvVar("A"); err != nil {
+		t.Fatalf("deleteEnvVar() error = %v", err)
+	}
+	if got := userValue(t, store, "A"); got != "<unset>" {
+		t.Errorf("A = %q, want it unset", got)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/dryrun"
+	"github.com/doraemonkeys/menv/env"
+	"github.com/doraemonkeys/menv/path"
+)
+
+// dryRun runs the command against a store that keeps its writes in memory,
+// then prints the changes it would have made. Messages claiming a change
+// was made are muted meanwhile. With -check-only, pending changes are
+// reported as an error.
+func dryRun(args []string) error {
+	store := dryrun.New(env.CurrentStore())
+	env.UseStore(store)
+	color.MuteDone(true)
+	err := execute(args)
+	color.MuteDone(false)
+	if err != nil {
+		return err
+	}
+
+	changes, err := store.Changes()
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	if len(changes) == 0 {
+		color.Info("Dry run: no changes")
+		return nil
+	}
+	color.Info("Dry run: %d change(s), nothing was written", len(changes))
+	dryrun.Write(os.Stdout, changes, path.Conventions())
+
+	if *cmd.CheckOnly {
+		return fmt.Errorf("%d change(s) would be made", len(changes))
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Package dryrun provides a store that keeps writes in memory instead of
+// making them, to preview what a command would change.
+package dryrun
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/doraemonkeys/menv/env"
+)
+
+// Store reads through to a base store until a scope is written, then keeps
+// a copy of that scope in memory. The base store is never written.
+type Store struct {
+	base   env.Store
+	conv   env.Conventions
+	scopes map[env.Scope][]env.EnvVar
+}
+
+// New returns a Store previewing writes to base.
+func New(base env.Store) *Store {
+	return &Store{
+		base:   base,
+		conv:   env.ConventionsOf(base),
+		scopes: make(map[env.Scope][]env.EnvVar),
+	}
+}
+
+// Conventions returns the conventions of the base store.
+func (s *Store) Conventions() env.Conventions {
+	return s.conv
+}
+
+func (s *Store) List(scope env.Scope) ([]env.EnvVar, error) {
+	vars, ok := s.scopes[scope]
+	if !ok {
+		return s.base.List(scope)
+	}
+	result := append([]env.EnvVar(nil), vars...)
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Key) < strings.ToLower(result[j].Key)
+	})
+	return result, nil
+}
+
+func (s *Store) Get(scope env.Scope, key string) (env.EnvVar, bool, error) {
+	vars, ok := s.scopes[scope]
+	if !ok {
+		return s.base.Get(scope, key)
+	}
+	if i := s.index(vars, key); i != -1 {
+		return vars[i], true, nil
+	}
+	return env.EnvVar{}, false, nil
+}
+
+// Set records v, keeping the casing of an existing key on stores with
+// case-insensitive keys.
+func (s *Store) Set(scope env.Scope, v env.EnvVar) error {
+	vars, err := s.copyOf(scope)
+	if err != nil {
+		return err
+	}
+	if i := s.index(vars, v.Key); i != -1 {
+		v.Key = vars[i].Key
+		vars[i] = v
+	} else {
+		vars = append(vars, v)
+	}
+	s.scopes[scope] = vars
+	return nil
+}
+
+func (s *Store) Delete(scope env.Scope, key string) error {
+	vars, err := s.copyOf(scope)
+	if err != nil {
+		return err
+	}
+	i := s.index(vars, key)
+	if i == -1 {
+		return env.ErrNotFound
+	}
+	s.scopes[scope] = append(vars[:i], vars[i+1:]...)
+	return nil
+}
+
+// Changes returns the difference between the base store and the writes
+// recorded, user scope first, sorted by key. Writes that were undone or
+// that set a variable to its current value are left out.
+func (s *Store) Changes() ([]env.ChangeResult, error) {
+	var result []env.ChangeResult
+	for _, scope := range []env.Scope{env.ScopeUser, env.ScopeSystem} {
+		vars, ok := s.scopes[scope]
+		if !ok {
+			continue
+		}
+		before, err := s.base.List(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		var changes []env.ChangeResult
+		for _, v := range vars {
+			i := s.index(before, v.Key)
+			switch {
+			case i == -1:
+				changes = append(changes, env.ChangeResult{Scope: scope, Change: env.Created, New: v})
+			case before[i] != v:
+				changes = append(changes, env.ChangeResult{Scope: scope, Change: env.Updated, Old: before[i], Existed: true, New: v})
+			}
+		}
+		for _, v := range before {
+			if s.index(vars, v.Key) == -1 {
+				changes = append(changes, env.ChangeResult{Scope: scope, Change: env.Deleted, Old: v, Existed: true, New: env.EnvVar{Key: v.Key}})
+			}
+		}
+		sort.Slice(changes, func(i, j int) bool {
+			return strings.ToLower(changes[i].New.Key) < strings.ToLower(changes[j].New.Key)
+		})
+		result = append(result, changes...)
+	}
+	return result, nil
+}
+
+// copyOf returns the in-memory copy of scope, making it on first use.
+func (s *Store) copyOf(scope env.Scope) ([]env.EnvVar, error) {
+	if vars, ok := s.scopes[scope]; ok {
+		return vars, nil
+	}
+	vars, err := s.base.List(scope)
+	if err != nil {
+		return nil, err
+	}
+	s.scopes[scope] = vars
+	return vars, nil
+}
+
+func (s *Store) index(vars []env.EnvVar, key string) int {
+	for i, v := range vars {
+		if v.Key == key || !s.conv.CaseSensitive && strings.EqualFold(v.Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Write prints changes to w, one variable per line: + for created, - for
+// deleted and ~ for updated. The variable named conv.PathKey is shown as a
+// diff of its entries, split on conv.ListSeparator.
+func Write(w io.Writer, changes []env.ChangeResult, conv env.Conventions) {
+	for _, c := range changes {
+		key := c.New.Key
+		isList := key == conv.PathKey || !conv.CaseSensitive && strings.EqualFold(key, conv.PathKey)
+		switch {
+		case isList:
+			fmt.Fprintf(w, "%s %s [%s]\n", marker(c.Change), key, c.Scope)
+			var before, after []string
+			if c.Existed {
+				before = splitList(c.Old.Value, conv.ListSeparator)
+			}
+			if c.Change != env.Deleted {
+				after = splitList(c.New.Value, conv.ListSeparator)
+			}
+			for _, line := range diff(before, after) {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+		case c.Change == env.Created:
+			fmt.Fprintf(w, "+ %s=%s [%s]\n", key, c.New.Value, c.Scope)
+		case c.Change == env.Deleted:
+			fmt.Fprintf(w, "- %s=%s [%s]\n", key, c.Old.Value, c.Scope)
+		case c.Old.Value == c.New.Value:
+			fmt.Fprintf(w, "~ %s [%s]: %s -> %s\n", key, c.Scope, c.Old.Type, c.New.Type)
+		default:
+			fmt.Fprintf(w, "~ %s [%s]: %s -> %s\n", key, c.Scope, c.Old.Value, c.New.Value)
+		}
+	}
+}
+
+func marker(c env.Change) string {
+	switch c {
+	case env.Created:
+		return "+"
+	case env.Deleted:
+		return "-"
+	default:
+		return "~"
+	}
+}
+
+func splitList(value, sep string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, sep)
+}
+
+// diff returns the lines of a diff from a to b: entries only in a are
+// prefixed with "- ", entries only in b with "+ " and common entries with
+// two spaces. Common entries are those of a longest common subsequence, so
+// a moved entry shows as removed and added.
+func diff(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+	return lines
+}
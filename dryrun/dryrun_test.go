@@ -0,0 +1,167 @@
+package dryrun
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/doraemonkeys/menv/env"
+)
+
+func newBase(t *testing.T) env.Store {
+	t.Helper()
+	base := env.NewMemoryStore()
+	for _, v := range []env.EnvVar{
+		{Key: "Path", Value: `C:\a;C:\b;C:\c`, Type: env.TypeExpandString},
+		{Key: "GOPATH", Value: `C:\go`, Type: env.TypeString},
+		{Key: "OLD", Value: "x", Type: env.TypeString},
+	} {
+		if err := base.Set(env.ScopeUser, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return base
+}
+
+func TestStore_DoesNotWriteBase(t *testing.T) {
+	base := newBase(t)
+	s := New(base)
+
+	if err := s.Set(env.ScopeUser, env.EnvVar{Key: "gopath", Value: `D:\go`, Type: env.TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := s.Delete(env.ScopeUser, "OLD"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := s.Delete(env.ScopeUser, "MISSING"); !errors.Is(err, env.ErrNotFound) {
+		t.Errorf("Delete() of missing key error = %v, want ErrNotFound", err)
+	}
+
+	// The store sees its own writes, keeping the existing key's casing.
+	v, ok, _ := s.Get(env.ScopeUser, "GOPATH")
+	if !ok || v.Key != "GOPATH" || v.Value != `D:\go` {
+		t.Errorf("Get() = %v, %v, want GOPATH=D:\\go", v, ok)
+	}
+	if _, ok, _ := s.Get(env.ScopeUser, "OLD"); ok {
+		t.Error("Get() found a deleted variable")
+	}
+	if vars, _ := s.List(env.ScopeUser); len(vars) != 2 {
+		t.Errorf("List() = %v, want 2 variables", vars)
+	}
+
+	// The base store is untouched.
+	if v, _, _ := base.Get(env.ScopeUser, "GOPATH"); v.Value != `C:\go` {
+		t.Errorf("base GOPATH = %q, want C:\\go", v.Value)
+	}
+	if _, ok, _ := base.Get(env.ScopeUser, "OLD"); !ok {
+		t.Error("base OLD was deleted")
+	}
+}
+
+func TestStore_Changes(t *testing.T) {
+	s := New(newBase(t))
+
+	_ = s.Set(env.ScopeUser, env.EnvVar{Key: "NEW", Value: "1", Type: env.TypeString})
+	_ = s.Set(env.ScopeUser, env.EnvVar{Key: "GOPATH", Value: `C:\go`, Type: env.TypeString}) // unchanged
+	_ = s.Delete(env.ScopeUser, "OLD")
+	_ = s.Set(env.ScopeUser, env.EnvVar{Key: "Path", Value: `C:\b;C:\a;C:\d`, Type: env.TypeExpandString})
+	_ = s.Set(env.ScopeSystem, env.EnvVar{Key: "TMP", Value: "t", Type: env.TypeString})
+	_ = s.Delete(env.ScopeSystem, "TMP") // undone
+
+	changes, err := s.Changes()
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Change.String()+" "+c.New.Key)
+	}
+	want := []string{"created NEW", "deleted OLD", "updated Path"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Changes() = %v, want %v", got, want)
+	}
+
+	var out strings.Builder
+	Write(&out, changes, env.ConventionsOf(s))
+	wantOut := `+ NEW=1 [user]
+- OLD=x [user]
+~ Path [user]
+    - C:\a
+      C:\b
+    - C:\c
+    + C:\a
+    + C:\d
+`
+	if out.String() != wantOut {
+		t.Errorf("Write() =\n%s\nwant\n%s", out.String(), wantOut)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	conv := env.ConventionsOf(env.NewMemoryStore())
+	tests := []struct {
+		name   string
+		change env.ChangeResult
+		want   string
+	}{
+		{
+			name: "updated value",
+			change: env.ChangeResult{Scope: env.ScopeSystem, Change: env.Updated, Existed: true,
+				Old: env.EnvVar{Key: "A", Value: "1"}, New: env.EnvVar{Key: "A", Value: "2"}},
+			want: "~ A [system]: 1 -> 2\n",
+		},
+		{
+			name: "updated type",
+			change: env.ChangeResult{Scope: env.ScopeUser, Change: env.Updated, Existed: true,
+				Old: env.EnvVar{Key: "A", Value: "1", Type: env.TypeString},
+				New: env.EnvVar{Key: "A", Value: "1", Type: env.TypeExpandString}},
+			want: "~ A [user]: REG_SZ -> REG_EXPAND_SZ\n",
+		},
+		{
+			name: "created PATH",
+			change: env.ChangeResult{Scope: env.ScopeUser, Change: env.Created,
+				New: env.EnvVar{Key: "PATH", Value: `C:\a;C:\b`}},
+			want: "+ PATH [user]\n    + C:\\a\n    + C:\\b\n",
+		},
+		{
+			name: "deleted PATH",
+			change: env.ChangeResult{Scope: env.ScopeUser, Change: env.Deleted, Existed: true,
+				Old: env.EnvVar{Key: "Path", Value: `C:\a`}, New: env.EnvVar{Key: "Path"}},
+			want: "- Path [user]\n    - C:\\a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			Write(&out, []env.ChangeResult{tt.change}, conv)
+			if out.String() != tt.want {
+				t.Errorf("Write() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []string
+	}{
+		{name: "equal", a: []string{"a", "b"}, b: []string{"a", "b"}, want: []string{"  a", "  b"}},
+		{name: "append", a: []string{"a"}, b: []string{"a", "b"}, want: []string{"  a", "+ b"}},
+		{name: "remove", a: []string{"a", "b", "c"}, b: []string{"a", "c"}, want: []string{"  a", "- b", "  c"}},
+		{name: "move to front", a: []string{"a", "b", "c"}, b: []string{"c", "a", "b"},
+			want: []string{"+ c", "  a", "  b", "- c"}},
+		{name: "empty", a: nil, b: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diff(tt.a, tt.b)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("diff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestDryRun(t *testing.T) {
+	store := useStore(t, env.EnvVar{Key: "A", Value: "1"})
+
+	if err := dryRun([]string{"A", "1"}); err != nil {
+		t.Fatalf("dryRun() without changes error = %v", err)
+	}
+	env.UseStore(store)
+	if err := dryRun([]string{"A", "2"}); err != nil {
+		t.Fatalf("dryRun() error = %v", err)
+	}
+	env.UseStore(store)
+	if got := userValue(t, store, "A"); got != "1" {
+		t.Errorf("A = %q after a dry run, want 1", got)
+	}
+
+	setFlag(t, cmd.CheckOnly, true)
+	if err := dryRun([]string{"A", "2"}); err == nil {
+		t.Error("dryRun() with -check-only and pending changes error = nil, want an error")
+	}
+	env.UseStore(store)
+	if got := userValue(t, store, "A"); got != "1" {
+		t.Errorf("A = %q after -check-only, want 1", got)
+	}
+}
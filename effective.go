@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/env"
+	"github.com/doraemonkeys/menv/path"
+)
+
+func listEffective() error {
+	color.Info("Effective Environment Variables (system + user):")
+	vars, err := env.ListEffective()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	shadowed := 0
+	for _, v := range vars {
+		printEffectiveVar(v)
+		if v.Shadowed != nil {
+			shadowed++
+		}
+	}
+	fmt.Printf("\nTotal: %d", len(vars))
+	if shadowed > 0 {
+		fmt.Printf(", %s%d user var(s) shadow system vars%s", color.Yellow, shadowed, color.Reset)
+	}
+	fmt.Println()
+	return nil
+}
+
+func getEffective(key string) error {
+	v, ok, err := env.GetEffective(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		color.Warning("%s is not set", key)
+		return nil
+	}
+	printEffectiveVar(v)
+	if *cmd.Expand {
+		return printExpansion(v.Key, v.Value, env.ScopeUser)
+	}
+	return nil
+}
+
+func showEffectivePath() error {
+	color.Info("Effective %s (system + user):", path.Name())
+	entries, err := path.QueryEffectivePath()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	for i, e := range entries {
+		fmt.Printf("%s%3d%s  %s  %s(%s)%s\n", color.Cyan, i+1, color.Reset, e.Path, color.Blue, e.Source, color.Reset)
+	}
+	fmt.Printf("\nTotal: %d\n", len(entries))
+	return nil
+}
+
+// printEffectiveVar prints KEY=value with its type and source, followed by
+// the system value it shadows, if any.
+func printEffectiveVar(v env.EffectiveVar) {
+	source := v.Source.String()
+	if v.Concatenated {
+		source = "system+user"
+	}
+	fmt.Printf("%s%s%s=%s", color.Green, v.Key, color.Reset, v.Value)
+	if v.Type != "" {
+		fmt.Printf("  %s[%s]%s", color.Blue, v.Type, color.Reset)
+	}
+	fmt.Printf("  %s(%s)%s\n", color.Cyan, source, color.Reset)
+	if v.Shadowed != nil {
+		fmt.Printf("    %sshadows system value:%s %s\n", color.Yellow, color.Reset, v.Shadowed.Value)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestEffective(t *testing.T) {
+	store := useStore(t,
+		env.EnvVar{Key: "JAVA_HOME", Value: `C:\jdk21`},
+		env.EnvVar{Key: "Path", Value: `%JAVA_HOME%\bin`, Type: env.TypeExpandString},
+	)
+	for _, v := range []env.EnvVar{
+		{Key: "JAVA_HOME", Value: `C:\jdk17`},
+		{Key: "Path", Value: `C:\Windows`, Type: env.TypeExpandString},
+	} {
+		if err := store.Set(env.ScopeSystem, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setFlag(t, cmd.Effective, true)
+	setFlag(t, cmd.Expand, true)
+
+	if err := listEnvVars(); err != nil {
+		t.Errorf("listEnvVars(-effective) error = %v", err)
+	}
+	for _, key := range []string{"Path", "JAVA_HOME", "MISSING"} {
+		if err := getEnvVar(key); err != nil {
+			t.Errorf("getEnvVar(-effective %s) error = %v", key, err)
+		}
+	}
+	if err := showPath(); err != nil {
+		t.Errorf("showPath(-effective) error = %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"time"
 )
 
@@ -48,30 +49,76 @@ func Backup(filename string, isSystem bool) (int, error) {
 	return len(envVars), nil
 }
 
-func Restore(filename string, isSystem bool) (int, error) {
-	data, err := os.ReadFile(filename)
+// RestoreMode selects how Restore treats the variables of the scope.
+type RestoreMode string
+
+const (
+	// RestoreMerge sets the variables of the backup and keeps the others.
+	RestoreMerge RestoreMode = "merge"
+	// RestoreReplace makes the scope match the backup, deleting variables
+	// that are not in it.
+	RestoreReplace RestoreMode = "replace"
+	// RestoreMissing only creates the variables of the backup that are not
+	// set.
+	RestoreMissing RestoreMode = "missing"
+)
+
+// ParseRestoreMode parses the name of a RestoreMode; "" is RestoreMerge.
+func ParseRestoreMode(s string) (RestoreMode, error) {
+	switch m := RestoreMode(s); m {
+	case "":
+		return RestoreMerge, nil
+	case RestoreMerge, RestoreReplace, RestoreMissing:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid restore mode %q, use merge, replace or missing", s)
+}
+
+// Restore sets the variables of a backup file, skipping those that already
+// hold the backed up value. If a write fails, the ones before it are rolled
+// back.
+func Restore(filename string, isSystem bool) (ChangeSummary, error) {
+	backup, err := LoadBackup(filename)
 	if err != nil {
-		return 0, err
+		return ChangeSummary{}, err
 	}
+	c, err := RestoreChanges(backup, isSystem, RestoreMerge)
+	if err != nil {
+		return ChangeSummary{}, err
+	}
+	return ApplyChanges(c)
+}
 
-	var backup BackupData
-	if err := json.Unmarshal(data, &backup); err != nil {
-		return 0, fmt.Errorf("invalid backup file: %w", err)
+// RestoreChanges returns the changeset restoring backup to the scope in
+// mode, to preview with Plan before it is applied.
+func RestoreChanges(backup *BackupData, isSystem bool, mode RestoreMode) (*Changeset, error) {
+	scope := ScopeOf(isSystem)
+	vars, err := current.List(scope)
+	if err != nil {
+		return nil, err
+	}
+	conv := ConventionsOf(current)
+	isSet := func(key string, vars []EnvVar) bool {
+		return slices.ContainsFunc(vars, func(v EnvVar) bool { return sameKey(v.Key, key, conv) })
 	}
 
+	// Backups made before types were recorded have an empty Type,
+	// which is resolved like a plain Set.
+	var c Changeset
 	for _, e := range backup.EnvVars {
-		if isSystem {
-			if err := SetSystem(e.Key, e.Value); err != nil {
-				return 0, fmt.Errorf("failed to set %s: %w", e.Key, err)
-			}
-		} else {
-			if err := Set(e.Key, e.Value); err != nil {
-				return 0, fmt.Errorf("failed to set %s: %w", e.Key, err)
+		if mode == RestoreMissing && isSet(e.Key, vars) {
+			continue
+		}
+		c.Set(scope, e)
+	}
+	if mode == RestoreReplace {
+		for _, v := range vars {
+			if !isSet(v.Key, backup.EnvVars) {
+				c.Delete(scope, v.Key)
 			}
 		}
 	}
-
-	return len(backup.EnvVars), nil
+	return &c, nil
 }
 
 func LoadBackup(filename string) (*BackupData, error) {
@@ -2,8 +2,10 @@ package env
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -130,3 +132,177 @@ func TestLoadBackup_FileNotFound(t *testing.T) {
 		t.Error("LoadBackup() expected error for nonexistent file")
 	}
 }
+
+func TestBackupRestore_PreservesType(t *testing.T) {
+	prev := CurrentStore()
+	store := NewMemoryStore()
+	UseStore(store)
+	t.Cleanup(func() { UseStore(prev) })
+
+	vars := []EnvVar{
+		{Key: "JAVA_HOME", Value: "C:\\Java", Type: TypeString},
+		{Key: "JAVA_BIN", Value: "%JAVA_HOME%\\bin", Type: TypeExpandString},
+	}
+	for _, v := range vars {
+		if err := store.Set(ScopeSystem, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), "backup.json")
+	if _, err := Backup(filename, true); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	UseStore(NewMemoryStore())
+	summary, err := Restore(filename, false)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if summary.Created != len(vars) {
+		t.Errorf("Restore() = %v, want %d created", summary, len(vars))
+	}
+
+	for _, want := range vars {
+		got, ok, _ := CurrentStore().Get(ScopeUser, want.Key)
+		if !ok || got != want {
+			t.Errorf("restored %s = %v, want %v", want.Key, got, want)
+		}
+	}
+
+	summary, err = Restore(filename, false)
+	if err != nil {
+		t.Fatalf("Restore() again error = %v", err)
+	}
+	if summary != (ChangeSummary{Unchanged: len(vars)}) {
+		t.Errorf("Restore() again = %v, want all unchanged", summary)
+	}
+}
+
+func TestRestoreChanges_Modes(t *testing.T) {
+	prev := CurrentStore()
+	t.Cleanup(func() { UseStore(prev) })
+
+	filename := filepath.Join(t.TempDir(), "backup.json")
+	UseStore(NewMemoryStore())
+	for _, v := range []EnvVar{{Key: "A", Value: "1"}, {Key: "B", Value: "2"}, {Key: "C", Value: "3"}} {
+		if err := CurrentStore().Set(ScopeUser, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Backup(filename, false); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	backup, err := LoadBackup(filename)
+	if err != nil {
+		t.Fatalf("LoadBackup() error = %v", err)
+	}
+
+	tests := []struct {
+		mode RestoreMode
+		want ChangeSummary
+		vars string
+	}{
+		{mode: RestoreMerge, want: ChangeSummary{Created: 1, Updated: 1, Unchanged: 1}, vars: "A=1 B=2 C=3 D=4"},
+		{mode: RestoreReplace, want: ChangeSummary{Created: 1, Updated: 1, Deleted: 1, Unchanged: 1}, vars: "A=1 B=2 C=3"},
+		{mode: RestoreMissing, want: ChangeSummary{Created: 1}, vars: "A=1 B=changed C=3 D=4"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			// Since the backup, B changed, C was deleted and D was added.
+			store := NewMemoryStore()
+			for _, v := range []EnvVar{{Key: "A", Value: "1"}, {Key: "B", Value: "changed"}, {Key: "D", Value: "4"}} {
+				_ = store.Set(ScopeUser, v)
+			}
+			UseStore(store)
+
+			c, err := RestoreChanges(backup, false, tt.mode)
+			if err != nil {
+				t.Fatalf("RestoreChanges() error = %v", err)
+			}
+			results, err := c.Apply()
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			var got ChangeSummary
+			for _, r := range results {
+				got.Add(r.Change)
+			}
+			if got != tt.want {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+
+			list, _ := store.List(ScopeUser)
+			var vars []string
+			for _, v := range list {
+				vars = append(vars, v.Key+"="+v.Value)
+			}
+			if s := strings.Join(vars, " "); s != tt.vars {
+				t.Errorf("vars = %s, want %s", s, tt.vars)
+			}
+		})
+	}
+}
+
+func TestParseRestoreMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    RestoreMode
+		wantErr bool
+	}{
+		{in: "", want: RestoreMerge},
+		{in: "merge", want: RestoreMerge},
+		{in: "replace", want: RestoreReplace},
+		{in: "missing", want: RestoreMissing},
+		{in: "overwrite", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRestoreMode(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRestoreMode(%q) = %q, %v, want %q, wantErr %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPlannedChanges(t *testing.T) {
+	prev := CurrentStore()
+	store := NewMemoryStore()
+	UseStore(store)
+	t.Cleanup(func() { UseStore(prev) })
+	for _, v := range []EnvVar{{Key: "A", Value: "1"}, {Key: "B", Value: "2"}, {Key: "C", Value: "3"}} {
+		_ = store.Set(ScopeUser, v)
+	}
+
+	var c Changeset
+	c.Set(ScopeUser, EnvVar{Key: "A", Value: "1"})
+	c.Set(ScopeUser, EnvVar{Key: "B", Value: "20"})
+	c.Delete(ScopeUser, "C")
+	c.Set(ScopeUser, EnvVar{Key: "D", Value: "4"})
+	plan, err := c.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	planned := PlannedChanges(plan)
+	if planned.Len() != 3 {
+		t.Errorf("PlannedChanges().Len() = %d, want the 3 writes that change something", planned.Len())
+	}
+
+	// C changed after the plan was shown, so nothing is written.
+	_ = store.Set(ScopeUser, EnvVar{Key: "C", Value: "30"})
+	if _, err := planned.Apply(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Apply() error = %v, want %v", err, ErrConflict)
+	}
+	if v, _, _ := store.Get(ScopeUser, "B"); v.Value != "2" {
+		t.Errorf("B = %q after a conflict, want it untouched", v.Value)
+	}
+
+	_ = store.Set(ScopeUser, EnvVar{Key: "C", Value: "3"})
+	summary, err := ApplyChanges(planned)
+	if err != nil {
+		t.Fatalf("ApplyChanges() error = %v", err)
+	}
+	if summary != (ChangeSummary{Created: 1, Updated: 1, Deleted: 1}) {
+		t.Errorf("ApplyChanges() = %v, want only the planned changes", summary)
+	}
+}
@@ -0,0 +1,215 @@
+package env
+
+import (
+	"strings"
+
+	"github.com/doraemonkeys/menv/color"
+)
+
+// Dependent is a variable whose value references a variable that is about
+// to be deleted.
+type Dependent struct {
+	GraphNode
+	// Entries are the entries referencing the variable when the dependent
+	// is a list variable like PATH; nil otherwise.
+	Entries []string
+}
+
+// FindDependents returns the variables that would be left with a dangling
+// reference if key were deleted from scope. User variables referencing a
+// user variable that the system scope also defines are not affected, as
+// the reference falls back to the system value.
+func FindDependents(scope Scope, key string) ([]Dependent, error) {
+	return findDependents(scope, key, true)
+}
+
+// findDependents returns the variables referencing key in scope. With
+// brokenOnly, those that would fall back to a system variable are left out.
+func findDependents(scope Scope, key string, brokenOnly bool) ([]Dependent, error) {
+	g, err := BuildGraph()
+	if err != nil {
+		return nil, err
+	}
+	t := g.find(scope, key)
+	if t == -1 {
+		return nil, nil
+	}
+	fallback := brokenOnly && scope == ScopeUser && g.find(ScopeSystem, key) != -1
+
+	var result []Dependent
+	for _, i := range g.Dependents(t) {
+		n := g.Nodes[i]
+		if fallback && n.Scope == ScopeUser {
+			continue
+		}
+		d := Dependent{GraphNode: n}
+		if IsConcatenated(n.Key, g.conv) {
+			d.Entries = referencingEntries(n.Value, key, g.conv)
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+// DeleteCascade deletes key from scope along with everything that depends
+// on it: entries of list variables referencing it are removed, and other
+// dependent variables are deleted in turn. All the writes are applied as one
+// Changeset, so a failure leaves every variable as it was.
+func DeleteCascade(scope Scope, key string) error {
+	cs := cascade{conv: ConventionsOf(current), visited: make(map[string]bool)}
+	if err := cs.collect(scope, key); err != nil {
+		return err
+	}
+
+	var c Changeset
+	for _, e := range cs.edits {
+		c.SetIf(e.Scope, e.EnvVar, e.old)
+	}
+	for _, d := range cs.deletes {
+		c.Delete(d.Scope, d.Key)
+	}
+	if _, err := c.Apply(); err != nil {
+		return err
+	}
+
+	for _, e := range cs.edits {
+		for _, entry := range e.removed {
+			color.Done("removed %s [%s %s]", entry, e.Scope, e.Key)
+		}
+	}
+	for _, d := range cs.deletes {
+		color.Done("unset %s%s", d.Key, scopeSuffix(d.Scope))
+	}
+	return nil
+}
+
+// cascade collects the writes of DeleteCascade without making them.
+type cascade struct {
+	conv    Conventions
+	visited map[string]bool
+	// edits are the list variables losing entries, and deletes the
+	// variables to delete, dependents first.
+	edits   []*listEdit
+	deletes []GraphNode
+}
+
+// listEdit is a list variable with entries removed from its old value.
+type listEdit struct {
+	GraphNode
+	old     string
+	removed []string
+}
+
+func (cs *cascade) collect(scope Scope, key string) error {
+	id := scope.String() + "\x00" + key
+	if !cs.conv.CaseSensitive {
+		id = strings.ToLower(id)
+	}
+	if cs.visited[id] {
+		return nil
+	}
+	cs.visited[id] = true
+
+	deps, err := FindDependents(scope, key)
+	if err != nil {
+		return err
+	}
+	for _, d := range deps {
+		if d.Entries == nil {
+			if err := cs.collect(d.Scope, d.Key); err != nil {
+				return err
+			}
+			continue
+		}
+		// A list variable can reference several of the deleted
+		// variables, so its entries are removed from the pending value.
+		e := cs.edit(d.GraphNode)
+		kept := strings.Split(e.Value, cs.conv.ListSeparator)
+		for _, entry := range d.Entries {
+			kept = removeEntry(kept, entry)
+		}
+		e.Value = strings.Join(kept, cs.conv.ListSeparator)
+		e.removed = append(e.removed, d.Entries...)
+	}
+	cs.deletes = append(cs.deletes, GraphNode{Scope: scope, EnvVar: EnvVar{Key: key}})
+	return nil
+}
+
+// edit returns the pending edit of the list variable n, starting one if
+// there is none.
+func (cs *cascade) edit(n GraphNode) *listEdit {
+	for _, e := range cs.edits {
+		if e.Scope == n.Scope && sameKey(e.Key, n.Key, cs.conv) {
+			return e
+		}
+	}
+	e := &listEdit{GraphNode: n, old: n.Value}
+	cs.edits = append(cs.edits, e)
+	return e
+}
+
+// DeleteInline deletes key from scope after replacing the references to it
+// in its dependents with its value. The dependents and the deletion are
+// applied as one Changeset.
+func DeleteInline(scope Scope, key string) error {
+	v, ok, err := current.Get(scope, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	deps, err := FindDependents(scope, key)
+	if err != nil {
+		return err
+	}
+
+	conv := ConventionsOf(current)
+	var c Changeset
+	for _, d := range deps {
+		inlined := d.EnvVar
+		inlined.Value = replaceReferences(d.Value, key, conv, func(string) string { return v.Value })
+		c.SetIf(d.Scope, inlined, d.Value)
+	}
+	c.Delete(scope, key)
+	if _, err := c.Apply(); err != nil {
+		return err
+	}
+
+	for _, d := range deps {
+		color.Done("inline %s into %s [%s]", v.Key, d.Key, d.Scope)
+	}
+	color.Done("unset %s%s", key, scopeSuffix(scope))
+	return nil
+}
+
+// referencingEntries returns the entries of a list value that reference name.
+func referencingEntries(value, name string, conv Conventions) []string {
+	var result []string
+	for _, entry := range strings.Split(value, conv.ListSeparator) {
+		if replaceReferences(entry, name, conv, func(string) string { return "" }) != entry {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// replaceReferences replaces each reference to name in value by repl(ref).
+func replaceReferences(value, name string, conv Conventions, repl func(ref string) string) string {
+	return conv.Reference.ReplaceAllStringFunc(value, func(ref string) string {
+		if sameKey(referenceName(ref), name, conv) {
+			return repl(ref)
+		}
+		return ref
+	})
+}
+
+// removeEntry removes the first occurrence of entry from entries.
+func removeEntry(entries []string, entry string) []string {
+	for i, e := range entries {
+		if e == entry {
+			return append(entries[:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,163 @@
+package env
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// useDependentsStore installs a store where MAVEN_HOME and both PATHs
+// reference JAVA_HOME.
+func useDependentsStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	s := NewMemoryStore()
+	prev := CurrentStore()
+	UseStore(s)
+	t.Cleanup(func() { UseStore(prev) })
+
+	for _, v := range []EnvVar{
+		{Key: "JAVA_HOME", Value: "C:\\jdk", Type: TypeString},
+		{Key: "MAVEN_HOME", Value: "%JAVA_HOME%\\..\\maven", Type: TypeExpandString},
+		{Key: "M2", Value: "%MAVEN_HOME%\\bin", Type: TypeExpandString},
+		{Key: "Path", Value: "C:\\bin;%java_home%\\bin;%MAVEN_HOME%\\bin;%JAVA_HOME%\\jre\\bin", Type: TypeExpandString},
+	} {
+		if err := s.Set(ScopeUser, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Set(ScopeSystem, EnvVar{Key: "Path", Value: "%JAVA_HOME%\\bin", Type: TypeExpandString}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestFindDependents(t *testing.T) {
+	useDependentsStore(t)
+
+	deps, err := FindDependents(ScopeUser, "java_home")
+	if err != nil {
+		t.Fatalf("FindDependents() error = %v", err)
+	}
+	var got []string
+	for _, d := range deps {
+		got = append(got, d.Key+"="+d.Value)
+	}
+	want := []string{
+		"MAVEN_HOME=%JAVA_HOME%\\..\\maven",
+		"Path=C:\\bin;%java_home%\\bin;%MAVEN_HOME%\\bin;%JAVA_HOME%\\jre\\bin",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("FindDependents() = %v, want %v", got, want)
+	}
+	if deps[0].Entries != nil {
+		t.Errorf("MAVEN_HOME entries = %v, want nil", deps[0].Entries)
+	}
+	if want := []string{"%java_home%\\bin", "%JAVA_HOME%\\jre\\bin"}; !reflect.DeepEqual(deps[1].Entries, want) {
+		t.Errorf("Path entries = %v, want %v", deps[1].Entries, want)
+	}
+
+	// The system PATH cannot see user variables.
+	if deps, _ := FindDependents(ScopeSystem, "JAVA_HOME"); deps != nil {
+		t.Errorf("FindDependents(system) = %v, want none", deps)
+	}
+	if deps, _ := FindDependents(ScopeUser, "MISSING"); deps != nil {
+		t.Errorf("FindDependents(MISSING) = %v, want none", deps)
+	}
+}
+
+func TestFindDependents_SystemFallback(t *testing.T) {
+	s := useDependentsStore(t)
+	_ = s.Set(ScopeSystem, EnvVar{Key: "JAVA_HOME", Value: "C:\\sysjdk", Type: TypeString})
+
+	deps, err := FindDependents(ScopeUser, "JAVA_HOME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deps != nil {
+		t.Errorf("FindDependents() = %v, want none while system JAVA_HOME remains", deps)
+	}
+
+	// Deleting the system variable breaks the system PATH only.
+	deps, _ = FindDependents(ScopeSystem, "JAVA_HOME")
+	if len(deps) != 1 || deps[0].Scope != ScopeSystem || deps[0].Key != "Path" {
+		t.Errorf("FindDependents(system) = %v, want system Path", deps)
+	}
+}
+
+func TestDeleteCascade(t *testing.T) {
+	s := useDependentsStore(t)
+
+	if err := DeleteCascade(ScopeUser, "JAVA_HOME"); err != nil {
+		t.Fatalf("DeleteCascade() error = %v", err)
+	}
+
+	got, _ := s.List(ScopeUser)
+	want := []EnvVar{{Key: "Path", Value: "C:\\bin", Type: TypeExpandString}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("user vars = %v, want %v", got, want)
+	}
+}
+
+func TestDeleteCascade_Cycle(t *testing.T) {
+	s := useDependentsStore(t)
+	_ = s.Set(ScopeUser, EnvVar{Key: "A", Value: "%B%", Type: TypeExpandString})
+	_ = s.Set(ScopeUser, EnvVar{Key: "B", Value: "%A%", Type: TypeExpandString})
+
+	if err := DeleteCascade(ScopeUser, "A"); err != nil {
+		t.Fatalf("DeleteCascade() error = %v", err)
+	}
+	for _, key := range []string{"A", "B"} {
+		if _, ok, _ := s.Get(ScopeUser, key); ok {
+			t.Errorf("%s still set", key)
+		}
+	}
+}
+
+func TestDeleteInline(t *testing.T) {
+	s := useDependentsStore(t)
+
+	if err := DeleteInline(ScopeUser, "JAVA_HOME"); err != nil {
+		t.Fatalf("DeleteInline() error = %v", err)
+	}
+
+	got, _ := s.List(ScopeUser)
+	want := []EnvVar{
+		{Key: "M2", Value: "%MAVEN_HOME%\\bin", Type: TypeExpandString},
+		{Key: "MAVEN_HOME", Value: "C:\\jdk\\..\\maven", Type: TypeExpandString},
+		{Key: "Path", Value: "C:\\bin;C:\\jdk\\bin;%MAVEN_HOME%\\bin;C:\\jdk\\jre\\bin", Type: TypeExpandString},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("user vars = %v, want %v", got, want)
+	}
+
+	if err := DeleteInline(ScopeUser, "JAVA_HOME"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteInline(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteCascadeInline_RollBackOnFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		delete func(Scope, string) error
+		writes int
+	}{
+		{name: "cascade", delete: DeleteCascade, writes: 4},
+		{name: "inline", delete: DeleteInline, writes: 3},
+	}
+	for _, tt := range tests {
+		for n := 1; n <= tt.writes; n++ {
+			t.Run(tt.name, func(t *testing.T) {
+				s := useDependentsStore(t)
+				before, _ := s.List(ScopeUser)
+				UseStore(&failingStore{Store: s, n: n})
+
+				if err := tt.delete(ScopeUser, "JAVA_HOME"); !errors.Is(err, errWriteFailed) {
+					t.Fatalf("error = %v, want the failed write", err)
+				}
+				if after, _ := s.List(ScopeUser); !reflect.DeepEqual(after, before) {
+					t.Errorf("write %d failed: user vars = %v, want %v", n, after, before)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package env
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// concatenatedKeys lists the variables besides PATH whose user value is
+// appended to the system value instead of replacing it, as Windows does
+// when composing the logon environment.
+var concatenatedKeys = []string{"LibPath", "Os2LibPath"}
+
+// EffectiveVar is a variable of the merged environment that new processes
+// see: system variables overlaid with user variables.
+type EffectiveVar struct {
+	EnvVar
+	// Source is the scope the value came from. Concatenated variables
+	// report ScopeUser when the user scope contributed to them.
+	Source Scope
+	// Concatenated reports that the value is the system value followed by
+	// the user value, as for PATH.
+	Concatenated bool
+	// Shadowed is the system variable hidden by a user variable of the
+	// same name, if any.
+	Shadowed *EnvVar
+}
+
+// ListEffective returns the merged environment of the current store.
+// Stores without a system scope contribute only their user variables.
+func ListEffective() ([]EffectiveVar, error) {
+	system, err := current.List(ScopeSystem)
+	if err != nil && !errors.Is(err, errNoSystemScope) {
+		return nil, err
+	}
+	user, err := current.List(ScopeUser)
+	if err != nil {
+		return nil, err
+	}
+	return mergeScopes(system, user, ConventionsOf(current)), nil
+}
+
+// GetEffective returns one variable of the merged environment.
+func GetEffective(key string) (EffectiveVar, bool, error) {
+	vars, err := ListEffective()
+	if err != nil {
+		return EffectiveVar{}, false, err
+	}
+	conv := ConventionsOf(current)
+	for _, v := range vars {
+		if sameKey(v.Key, key, conv) {
+			return v, true, nil
+		}
+	}
+	return EffectiveVar{}, false, nil
+}
+
+func mergeScopes(system, user []EnvVar, conv Conventions) []EffectiveVar {
+	result := make([]EffectiveVar, 0, len(system)+len(user))
+	for _, v := range system {
+		result = append(result, EffectiveVar{EnvVar: v, Source: ScopeSystem})
+	}
+
+	for _, u := range user {
+		i := -1
+		for j := range result {
+			if sameKey(result[j].Key, u.Key, conv) {
+				i = j
+				break
+			}
+		}
+
+		switch {
+		case i == -1:
+			result = append(result, EffectiveVar{EnvVar: u, Source: ScopeUser})
+		case IsConcatenated(u.Key, conv):
+			result[i] = concatenate(result[i].EnvVar, u, conv)
+		default:
+			shadowed := result[i].EnvVar
+			result[i] = EffectiveVar{EnvVar: u, Source: ScopeUser, Shadowed: &shadowed}
+		}
+	}
+
+	sortEffectiveVars(result)
+	return result
+}
+
+func concatenate(system, user EnvVar, conv Conventions) EffectiveVar {
+	v := system
+	switch {
+	case system.Value == "":
+		v.Value = user.Value
+	case user.Value != "":
+		v.Value = strings.TrimSuffix(system.Value, conv.ListSeparator) + conv.ListSeparator + user.Value
+	}
+	if user.Type == TypeExpandString {
+		v.Type = TypeExpandString
+	}
+	return EffectiveVar{EnvVar: v, Source: ScopeUser, Concatenated: true}
+}
+
+// IsConcatenated reports whether the user value of key is appended to the
+// system value, like PATH, rather than replacing it.
+func IsConcatenated(key string, conv Conventions) bool {
+	if sameKey(key, conv.PathKey, conv) {
+		return true
+	}
+	for _, k := range concatenatedKeys {
+		if sameKey(key, k, conv) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameKey(a, b string, conv Conventions) bool {
+	if conv.CaseSensitive {
+		return a == b
+	}
+	return strings.EqualFold(a, b)
+}
+
+func sortEffectiveVars(vars []EffectiveVar) {
+	sort.Slice(vars, func(i, j int) bool {
+		return strings.ToLower(vars[i].Key) < strings.ToLower(vars[j].Key)
+	})
+}
@@ -0,0 +1,86 @@
+package env
+
+import "testing"
+
+func TestMergeScopes(t *testing.T) {
+	system := []EnvVar{
+		{Key: "OS", Value: "Windows_NT", Type: TypeString},
+		{Key: "Path", Value: "C:\\Windows;", Type: TypeExpandString},
+		{Key: "TEMP", Value: "C:\\Windows\\Temp", Type: TypeExpandString},
+	}
+	user := []EnvVar{
+		{Key: "PATH", Value: "C:\\bin", Type: TypeString},
+		{Key: "temp", Value: "%USERPROFILE%\\Temp", Type: TypeExpandString},
+		{Key: "GOPATH", Value: "C:\\Go", Type: TypeString},
+	}
+
+	got := mergeScopes(system, user, windowsConventions)
+
+	want := []EffectiveVar{
+		{EnvVar: EnvVar{Key: "GOPATH", Value: "C:\\Go", Type: TypeString}, Source: ScopeUser},
+		{EnvVar: EnvVar{Key: "OS", Value: "Windows_NT", Type: TypeString}, Source: ScopeSystem},
+		{EnvVar: EnvVar{Key: "Path", Value: "C:\\Windows;C:\\bin", Type: TypeExpandString}, Source: ScopeUser, Concatenated: true},
+		{EnvVar: EnvVar{Key: "temp", Value: "%USERPROFILE%\\Temp", Type: TypeExpandString}, Source: ScopeUser, Shadowed: &system[2]},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("mergeScopes() = %v, want %v", got, want)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.EnvVar != w.EnvVar || g.Source != w.Source || g.Concatenated != w.Concatenated {
+			t.Errorf("mergeScopes()[%d] = %+v, want %+v", i, g, w)
+		}
+		if (g.Shadowed == nil) != (w.Shadowed == nil) || g.Shadowed != nil && *g.Shadowed != *w.Shadowed {
+			t.Errorf("mergeScopes()[%d].Shadowed = %v, want %v", i, g.Shadowed, w.Shadowed)
+		}
+	}
+}
+
+func TestMergeScopes_CaseSensitive(t *testing.T) {
+	system := []EnvVar{{Key: "PATH", Value: "/usr/bin"}, {Key: "lang", Value: "C"}}
+	user := []EnvVar{{Key: "PATH", Value: "/opt/bin"}, {Key: "LANG", Value: "en_US"}}
+
+	got := mergeScopes(system, user, posixConventions)
+	if len(got) != 3 {
+		t.Fatalf("mergeScopes() = %v, want 3 variables", got)
+	}
+	for _, v := range got {
+		if v.Shadowed != nil {
+			t.Errorf("%s shadows %v, want no shadowing across case", v.Key, v.Shadowed)
+		}
+		if v.Key == "PATH" && v.Value != "/usr/bin:/opt/bin" {
+			t.Errorf("PATH = %q, want /usr/bin:/opt/bin", v.Value)
+		}
+	}
+}
+
+func TestConcatenate_EmptySides(t *testing.T) {
+	if got := concatenate(EnvVar{Key: "Path"}, EnvVar{Key: "Path", Value: "C:\\bin"}, windowsConventions); got.Value != "C:\\bin" {
+		t.Errorf("concatenate() = %q, want C:\\bin", got.Value)
+	}
+	if got := concatenate(EnvVar{Key: "Path", Value: "C:\\Windows"}, EnvVar{Key: "Path"}, windowsConventions); got.Value != "C:\\Windows" {
+		t.Errorf("concatenate() = %q, want C:\\Windows", got.Value)
+	}
+}
+
+func TestGetEffective(t *testing.T) {
+	prev := CurrentStore()
+	store := NewMemoryStore()
+	UseStore(store)
+	t.Cleanup(func() { UseStore(prev) })
+
+	_ = store.Set(ScopeSystem, EnvVar{Key: "JAVA_HOME", Value: "C:\\jdk8", Type: TypeString})
+	_ = store.Set(ScopeUser, EnvVar{Key: "JAVA_HOME", Value: "C:\\jdk17", Type: TypeString})
+
+	v, ok, err := GetEffective("java_home")
+	if err != nil || !ok {
+		t.Fatalf("GetEffective() = %v, %v, %v, want found", v, ok, err)
+	}
+	if v.Value != "C:\\jdk17" || v.Source != ScopeUser || v.Shadowed == nil || v.Shadowed.Value != "C:\\jdk8" {
+		t.Errorf("GetEffective() = %+v, want user value shadowing C:\\jdk8", v)
+	}
+
+	if _, ok, _ := GetEffective("MISSING"); ok {
+		t.Error("GetEffective(MISSING) found a variable")
+	}
+}
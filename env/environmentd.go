@@ -0,0 +1,171 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const environmentDHeader = "# Managed by menv; edits to this file may be overwritten."
+
+// errNoSystemScope is returned by stores that only hold user variables.
+var errNoSystemScope = errors.New("this store has no system scope")
+
+// EnvironmentDStore is a Store for Linux desktops that keeps user variables
+// in a dedicated systemd environment.d file (~/.config/environment.d/menv.conf
+// by default), the counterpart of HKCU\Environment. It has no system scope.
+//
+// The user PATH holds only the user's entries and is appended to the
+// inherited $PATH. systemd expands $VAR references in every value, so a
+// value's type is REG_EXPAND_SZ exactly when it contains a reference.
+type EnvironmentDStore struct {
+	File string
+}
+
+// NewEnvironmentDStore returns an EnvironmentDStore for file.
+func NewEnvironmentDStore(file string) *EnvironmentDStore {
+	return &EnvironmentDStore{File: file}
+}
+
+// defaultEnvironmentDStore returns an EnvironmentDStore for
+// $XDG_CONFIG_HOME/environment.d/menv.conf.
+func defaultEnvironmentDStore() *EnvironmentDStore {
+	file := ""
+	if dir, err := os.UserConfigDir(); err == nil {
+		file = filepath.Join(dir, "environment.d", "menv.conf")
+	}
+	return NewEnvironmentDStore(file)
+}
+
+func (e *EnvironmentDStore) Conventions() Conventions {
+	return posixConventions
+}
+
+func (e *EnvironmentDStore) List(scope Scope) ([]EnvVar, error) {
+	vars, err := e.load(scope)
+	if err != nil {
+		return nil, err
+	}
+	sortEnvVars(vars)
+	return vars, nil
+}
+
+func (e *EnvironmentDStore) Get(scope Scope, key string) (EnvVar, bool, error) {
+	vars, err := e.load(scope)
+	if err != nil {
+		return EnvVar{}, false, err
+	}
+	if i := indexOfExactVar(vars, key); i != -1 {
+		return vars[i], true, nil
+	}
+	return EnvVar{}, false, nil
+}
+
+func (e *EnvironmentDStore) Set(scope Scope, v EnvVar) error {
+	vars, err := e.load(scope)
+	if err != nil {
+		return err
+	}
+	if i := indexOfExactVar(vars, v.Key); i != -1 {
+		vars[i] = v
+	} else {
+		vars = append(vars, v)
+	}
+	return e.save(vars)
+}
+
+func (e *EnvironmentDStore) Delete(scope Scope, key string) error {
+	vars, err := e.load(scope)
+	if err != nil {
+		return err
+	}
+	i := indexOfExactVar(vars, key)
+	if i == -1 {
+		return ErrNotFound
+	}
+	return e.save(append(vars[:i], vars[i+1:]...))
+}
+
+func (e *EnvironmentDStore) load(scope Scope) ([]EnvVar, error) {
+	if scope == ScopeSystem {
+		return nil, errNoSystemScope
+	}
+	if e.File == "" {
+		return nil, errors.New("no environment.d file configured")
+	}
+
+	content, err := os.ReadFile(e.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var vars []EnvVar
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		if key == posixConventions.PathKey {
+			raw = trimInheritedPath(raw)
+		}
+		value, _ := unquoteShell(raw)
+		vars = append(vars, EnvVar{Key: key, Value: value, Type: inferShellType(value)})
+	}
+	return vars, nil
+}
+
+func (e *EnvironmentDStore) save(vars []EnvVar) error {
+	var sb strings.Builder
+	sb.WriteString(environmentDHeader + "\n")
+	for _, v := range vars {
+		value := quoteEnvironmentD(v.Value)
+		if v.Key == posixConventions.PathKey {
+			value = "$PATH"
+			if v.Value != "" {
+				value += posixConventions.ListSeparator + quoteEnvironmentD(v.Value)
+			}
+		}
+		sb.WriteString(v.Key + "=" + value + "\n")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(e.File), 0755); err != nil {
+		return err
+	}
+	return writeFile(e.File, []byte(sb.String()))
+}
+
+// trimInheritedPath removes a leading $PATH or ${PATH} entry.
+func trimInheritedPath(raw string) string {
+	for _, prefix := range []string{"$PATH", "${PATH}"} {
+		if rest, ok := strings.CutPrefix(raw, prefix); ok && (rest == "" || strings.HasPrefix(rest, posixConventions.ListSeparator)) {
+			return strings.TrimPrefix(rest, posixConventions.ListSeparator)
+		}
+	}
+	return raw
+}
+
+func inferShellType(value string) ValueType {
+	if shellReference.MatchString(value) {
+		return TypeExpandString
+	}
+	return TypeString
+}
+
+// quoteEnvironmentD double-quotes values that systemd would otherwise
+// split or strip; $ is left alone since systemd expands it anyway.
+func quoteEnvironmentD(value string) string {
+	if !strings.ContainsAny(value, " \t\"'\\#;") {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(value) + `"`
+}
@@ -0,0 +1,106 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvironmentDStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "environment.d", "menv.conf")
+	s := NewEnvironmentDStore(file)
+
+	vars := []EnvVar{
+		{Key: "JAVA_HOME", Value: "/opt/java", Type: TypeString},
+		{Key: "GOBIN", Value: "${HOME}/go/bin", Type: TypeExpandString},
+		{Key: "PATH", Value: "/opt/java/bin:/opt/My Tools", Type: TypeString},
+	}
+	for _, v := range vars {
+		if err := s.Set(ScopeUser, v); err != nil {
+			t.Fatalf("Set(%s) error = %v", v.Key, err)
+		}
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := environmentDHeader + "\n" +
+		"JAVA_HOME=/opt/java\n" +
+		"GOBIN=${HOME}/go/bin\n" +
+		"PATH=$PATH:\"/opt/java/bin:/opt/My Tools\"\n"
+	if string(content) != want {
+		t.Errorf("menv.conf =\n%s\nwant\n%s", content, want)
+	}
+
+	for _, want := range vars {
+		got, ok, err := s.Get(ScopeUser, want.Key)
+		if err != nil || !ok || got != want {
+			t.Errorf("Get(%s) = %v, %v, %v, want %v", want.Key, got, ok, err, want)
+		}
+	}
+
+	if err := s.Delete(ScopeUser, "GOBIN"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := s.Delete(ScopeUser, "GOBIN"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() of missing key error = %v, want ErrNotFound", err)
+	}
+	got, _ := s.List(ScopeUser)
+	if len(got) != 2 || got[0].Key != "JAVA_HOME" || got[1].Key != "PATH" {
+		t.Errorf("List() = %v, want JAVA_HOME and PATH", got)
+	}
+}
+
+func TestEnvironmentDStore_ReadsHandWrittenFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "menv.conf")
+	content := "# comment\n\nPATH=${PATH}:/opt/a:/opt/b\nEMPTY=\nQUOTED=\"a b\"\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewEnvironmentDStore(file).List(ScopeUser)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []EnvVar{
+		{Key: "EMPTY", Value: "", Type: TypeString},
+		{Key: "PATH", Value: "/opt/a:/opt/b", Type: TypeString},
+		{Key: "QUOTED", Value: "a b", Type: TypeString},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("List() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("List()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEnvironmentDStore_NoSystemScope(t *testing.T) {
+	s := NewEnvironmentDStore(filepath.Join(t.TempDir(), "menv.conf"))
+	if _, err := s.List(ScopeSystem); !errors.Is(err, errNoSystemScope) {
+		t.Errorf("List(ScopeSystem) error = %v, want errNoSystemScope", err)
+	}
+	if err := s.Set(ScopeSystem, EnvVar{Key: "A", Value: "b"}); !errors.Is(err, errNoSystemScope) {
+		t.Errorf("Set(ScopeSystem) error = %v, want errNoSystemScope", err)
+	}
+}
+
+func TestTrimInheritedPath(t *testing.T) {
+	tests := map[string]string{
+		"$PATH:/a:/b":   "/a:/b",
+		"${PATH}:/a":    "/a",
+		"$PATH":         "",
+		"/a:$PATH":      "/a:$PATH",
+		"$PATHEXT:/a":   "$PATHEXT:/a",
+		"/usr/bin:/bin": "/usr/bin:/bin",
+	}
+	for raw, want := range tests {
+		if got := trimInheritedPath(raw); got != want {
+			t.Errorf("trimInheritedPath(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package env
+
+import (
+	"os"
+	"strings"
+)
+
+// Expansion is the result of expanding the references in a value.
+type Expansion struct {
+	Value string
+	// Undefined lists referenced names that are defined nowhere.
+	Undefined []string
+	// Cycles lists reference chains that lead back to themselves,
+	// e.g. [A B A]. The looping reference is left unexpanded.
+	Cycles [][]string
+}
+
+// Expander expands variable references (%VAR% on Windows, $VAR elsewhere)
+// against a snapshot of a store rather than the possibly stale process
+// environment. Names the store does not define, such as USERPROFILE or
+// SystemRoot which Windows sets at logon, fall back to the process
+// environment.
+type Expander struct {
+	vars   []EnvVar
+	conv   Conventions
+	lookup func(string) (string, bool)
+}
+
+// NewExpander returns an Expander for values of the scope in the current
+// store. System values only see system variables, while user values see the
+// effective system+user environment, matching how Windows expands them.
+func NewExpander(scope Scope) (*Expander, error) {
+	var vars []EnvVar
+	if scope == ScopeSystem {
+		system, err := current.List(ScopeSystem)
+		if err != nil {
+			return nil, err
+		}
+		vars = system
+	} else {
+		effective, err := ListEffective()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range effective {
+			vars = append(vars, v.EnvVar)
+		}
+	}
+	return NewExpanderFrom(vars, ConventionsOf(current)), nil
+}
+
+// NewExpanderFrom returns an Expander over vars.
+func NewExpanderFrom(vars []EnvVar, conv Conventions) *Expander {
+	return &Expander{vars: vars, conv: conv, lookup: os.LookupEnv}
+}
+
+// Expand expands all references in value. References to REG_SZ variables
+// insert their value literally; REG_EXPAND_SZ values are expanded in turn.
+// Undefined references are kept as written on Windows and removed
+// elsewhere, as the respective shells do.
+func (x *Expander) Expand(value string) Expansion {
+	var result Expansion
+	result.Value = x.expand(value, nil, &result)
+	return result
+}
+
+func (x *Expander) expand(value string, stack []string, result *Expansion) string {
+	return x.conv.Reference.ReplaceAllStringFunc(value, func(ref string) string {
+		name := referenceName(ref)
+		for i, s := range stack {
+			if sameKey(s, name, x.conv) {
+				result.Cycles = append(result.Cycles, append(append([]string(nil), stack[i:]...), name))
+				return ref
+			}
+		}
+
+		if i := x.index(name); i != -1 {
+			v := x.vars[i]
+			if v.Type == TypeString {
+				return v.Value
+			}
+			return x.expand(v.Value, append(stack, name), result)
+		}
+		if value, ok := x.lookup(name); ok {
+			return value
+		}
+
+		result.Undefined = appendUnique(result.Undefined, name)
+		if strings.HasPrefix(ref, "%") {
+			return ref
+		}
+		return ""
+	})
+}
+
+func (x *Expander) index(name string) int {
+	for i, v := range x.vars {
+		if sameKey(v.Key, name, x.conv) {
+			return i
+		}
+	}
+	return -1
+}
+
+// referenceName returns NAME for %NAME%, $NAME and ${NAME}.
+func referenceName(ref string) string {
+	if strings.HasPrefix(ref, "%") {
+		return strings.Trim(ref, "%")
+	}
+	return strings.Trim(ref, "${}")
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,126 @@
+package env
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	vars := []EnvVar{
+		{Key: "JAVA_HOME", Value: "%TOOLS%\\jdk", Type: TypeExpandString},
+		{Key: "TOOLS", Value: "C:\\tools", Type: TypeString},
+		{Key: "LITERAL", Value: "%TOOLS%\\raw", Type: TypeString},
+		{Key: "SELF", Value: "x;%SELF%", Type: TypeExpandString},
+		{Key: "A", Value: "%B%", Type: TypeExpandString},
+		{Key: "B", Value: "%A%", Type: TypeExpandString},
+		{Key: "DANGLING", Value: "%NOWHERE%\\bin", Type: TypeExpandString},
+	}
+	x := NewExpanderFrom(vars, windowsConventions)
+	x.lookup = func(name string) (string, bool) {
+		if name == "USERPROFILE" {
+			return "C:\\Users\\me", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		name      string
+		value     string
+		want      string
+		undefined []string
+		cycles    [][]string
+	}{
+		{name: "plain", value: "C:\\bin", want: "C:\\bin"},
+		{name: "nested", value: "%JAVA_HOME%\\bin", want: "C:\\tools\\jdk\\bin"},
+		{name: "case insensitive", value: "%java_home%", want: "C:\\tools\\jdk"},
+		{name: "REG_SZ inserted literally", value: "%LITERAL%", want: "%TOOLS%\\raw"},
+		{name: "process fallback", value: "%USERPROFILE%\\go", want: "C:\\Users\\me\\go"},
+		{
+			name:      "undefined kept",
+			value:     "%MISSING%\\bin",
+			want:      "%MISSING%\\bin",
+			undefined: []string{"MISSING"},
+		},
+		{
+			name:      "undefined nested",
+			value:     "%DANGLING%",
+			want:      "%NOWHERE%\\bin",
+			undefined: []string{"NOWHERE"},
+		},
+		{
+			name:   "self cycle",
+			value:  "%SELF%",
+			want:   "x;%SELF%",
+			cycles: [][]string{{"SELF", "SELF"}},
+		},
+		{
+			name:   "mutual cycle",
+			value:  "%A%",
+			want:   "%A%",
+			cycles: [][]string{{"A", "B", "A"}},
+		},
+		{name: "lone percent", value: "100%", want: "100%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := x.Expand(tt.value)
+			if got.Value != tt.want {
+				t.Errorf("Expand(%q).Value = %q, want %q", tt.value, got.Value, tt.want)
+			}
+			if !reflect.DeepEqual(got.Undefined, tt.undefined) {
+				t.Errorf("Expand(%q).Undefined = %v, want %v", tt.value, got.Undefined, tt.undefined)
+			}
+			if !reflect.DeepEqual(got.Cycles, tt.cycles) {
+				t.Errorf("Expand(%q).Cycles = %v, want %v", tt.value, got.Cycles, tt.cycles)
+			}
+		})
+	}
+}
+
+func TestExpand_Posix(t *testing.T) {
+	x := NewExpanderFrom([]EnvVar{
+		{Key: "OPT", Value: "/opt", Type: TypeString},
+		{Key: "GOROOT", Value: "${OPT}/go", Type: TypeExpandString},
+		{Key: "goroot", Value: "/lower", Type: TypeString},
+	}, posixConventions)
+	x.lookup = func(string) (string, bool) { return "", false }
+
+	got := x.Expand("$GOROOT/bin:$goroot:$UNSET/x")
+	if want := "/opt/go/bin:/lower:/x"; got.Value != want {
+		t.Errorf("Expand().Value = %q, want %q", got.Value, want)
+	}
+	if want := []string{"UNSET"}; !reflect.DeepEqual(got.Undefined, want) {
+		t.Errorf("Expand().Undefined = %v, want %v", got.Undefined, want)
+	}
+}
+
+func TestNewExpander_Scope(t *testing.T) {
+	s := NewMemoryStore()
+	prev := CurrentStore()
+	UseStore(s)
+	t.Cleanup(func() { UseStore(prev) })
+	_ = s.Set(ScopeSystem, EnvVar{Key: "ROOT", Value: "C:\\sys", Type: TypeString})
+	_ = s.Set(ScopeUser, EnvVar{Key: "ROOT", Value: "C:\\user", Type: TypeString})
+	_ = s.Set(ScopeUser, EnvVar{Key: "ONLY_USER", Value: "u", Type: TypeString})
+
+	tests := []struct {
+		scope Scope
+		want  string
+	}{
+		{ScopeSystem, "C:\\sys;%ONLY_USER%"},
+		{ScopeUser, "C:\\user;u"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope.String(), func(t *testing.T) {
+			x, err := NewExpander(tt.scope)
+			if err != nil {
+				t.Fatalf("NewExpander() error = %v", err)
+			}
+			x.lookup = func(string) (string, bool) { return "", false }
+			if got := x.Expand("%ROOT%;%ONLY_USER%").Value; got != tt.want {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
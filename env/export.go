@@ -14,6 +14,9 @@ const (
 	FormatShell ExportFormat = "sh"
 	FormatBatch ExportFormat = "bat"
 	FormatJSON  ExportFormat = "json"
+	// FormatTypedJSON is JSON that also keeps the value type of each
+	// variable. It is never detected from the file name.
+	FormatTypedJSON ExportFormat = "json-typed"
 )
 
 func DetectFormat(filename string) ExportFormat {
@@ -31,8 +34,11 @@ func DetectFormat(filename string) ExportFormat {
 }
 
 func Export(filename string, envVars []EnvVar) error {
-	format := DetectFormat(filename)
+	return ExportAs(filename, DetectFormat(filename), envVars)
+}
 
+// ExportAs is Export with an explicit format.
+func ExportAs(filename string, format ExportFormat, envVars []EnvVar) error {
 	var content string
 	var err error
 
@@ -43,29 +49,45 @@ func Export(filename string, envVars []EnvVar) error {
 		content = formatBatch(envVars)
 	case FormatJSON:
 		content, err = formatJSON(envVars)
-		if err != nil {
-			return err
-		}
+	case FormatTypedJSON:
+		content, err = formatTypedJSON(envVars)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+	if err != nil {
+		return err
 	}
 
 	return os.WriteFile(filename, []byte(content), 0644)
 }
 
+// formatShell writes REG_EXPAND_SZ references as ${VAR} so they keep expanding.
 func formatShell(envVars []EnvVar) string {
 	var sb strings.Builder
 	sb.WriteString("#!/bin/bash\n\n")
 	for _, e := range envVars {
 		value := strings.ReplaceAll(e.Value, `"`, `\"`)
+		if e.Type == TypeExpandString {
+			value = varReference.ReplaceAllStringFunc(value, func(ref string) string {
+				return "${" + strings.Trim(ref, "%") + "}"
+			})
+		}
 		sb.WriteString(fmt.Sprintf("export %s=\"%s\"\n", e.Key, value))
 	}
 	return sb.String()
 }
 
+// formatBatch escapes '%' in REG_SZ values so that only REG_EXPAND_SZ
+// references are expanded by cmd.
 func formatBatch(envVars []EnvVar) string {
 	var sb strings.Builder
 	sb.WriteString("@echo off\r\n\r\n")
 	for _, e := range envVars {
-		sb.WriteString(fmt.Sprintf("SET %s=%s\r\n", e.Key, e.Value))
+		value := e.Value
+		if e.Type == TypeString {
+			value = strings.ReplaceAll(value, "%", "%%")
+		}
+		sb.WriteString(fmt.Sprintf("SET %s=%s\r\n", e.Key, value))
 	}
 	return sb.String()
 }
@@ -75,8 +97,27 @@ func formatJSON(envVars []EnvVar) (string, error) {
 	for _, e := range envVars {
 		envMap[e.Key] = e.Value
 	}
+	return marshalJSON(envMap)
+}
+
+// jsonValue is a variable in a typed JSON export.
+type jsonValue struct {
+	Value string    `json:"value"`
+	Type  ValueType `json:"type,omitempty"`
+}
+
+// formatTypedJSON writes each variable as {"value": ..., "type": ...} so
+// that the value type is kept.
+func formatTypedJSON(envVars []EnvVar) (string, error) {
+	envMap := make(map[string]jsonValue)
+	for _, e := range envVars {
+		envMap[e.Key] = jsonValue{Value: e.Value, Type: e.Type}
+	}
+	return marshalJSON(envMap)
+}
 
-	data, err := json.MarshalIndent(envMap, "", "  ")
+func marshalJSON(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		return "", err
 	}
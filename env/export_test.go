@@ -1,6 +1,7 @@
 package env
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -145,6 +146,16 @@ func TestFormatShell(t *testing.T) {
 			envVars: []EnvVar{{Key: "MSG", Value: `say "hello"`}},
 			want:    "#!/bin/bash\n\nexport MSG=\"say \\\"hello\\\"\"\n",
 		},
+		{
+			name:    "expand string references",
+			envVars: []EnvVar{{Key: "BIN", Value: "%JAVA_HOME%/bin:%HOME%", Type: TypeExpandString}},
+			want:    "#!/bin/bash\n\nexport BIN=\"${JAVA_HOME}/bin:${HOME}\"\n",
+		},
+		{
+			name:    "plain string keeps percent",
+			envVars: []EnvVar{{Key: "RATE", Value: "100%", Type: TypeString}},
+			want:    "#!/bin/bash\n\nexport RATE=\"100%\"\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -178,6 +189,16 @@ func TestFormatBatch(t *testing.T) {
 			envVars: []EnvVar{{Key: "FOO", Value: "bar"}, {Key: "BAZ", Value: "qux"}},
 			want:    "@echo off\r\n\r\nSET FOO=bar\r\nSET BAZ=qux\r\n",
 		},
+		{
+			name:    "expand string kept",
+			envVars: []EnvVar{{Key: "BIN", Value: "%JAVA_HOME%\\bin", Type: TypeExpandString}},
+			want:    "@echo off\r\n\r\nSET BIN=%JAVA_HOME%\\bin\r\n",
+		},
+		{
+			name:    "plain string percent escaped",
+			envVars: []EnvVar{{Key: "RATE", Value: "100%", Type: TypeString}},
+			want:    "@echo off\r\n\r\nSET RATE=100%%\r\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -240,3 +261,40 @@ func TestFormatJSONContent(t *testing.T) {
 		t.Errorf("formatJSON() = %q, want to contain FOO and bar", got)
 	}
 }
+
+func TestFormatJSON_Flat(t *testing.T) {
+	got, err := formatJSON([]EnvVar{{Key: "JAVA_BIN", Value: `%JAVA_HOME%\bin`, Type: TypeExpandString}})
+	if err != nil {
+		t.Fatalf("formatJSON() error = %v", err)
+	}
+
+	var parsed map[string]string
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("formatJSON() = %q, want a flat object: %v", got, err)
+	}
+	if parsed["JAVA_BIN"] != `%JAVA_HOME%\bin` {
+		t.Errorf("JAVA_BIN = %q, want %%JAVA_HOME%%\\bin", parsed["JAVA_BIN"])
+	}
+}
+
+func TestFormatTypedJSON_KeepsType(t *testing.T) {
+	envVars := []EnvVar{
+		{Key: "JAVA_BIN", Value: `%JAVA_HOME%\bin`, Type: TypeExpandString},
+		{Key: "COUNT", Value: "1", Type: TypeDWord},
+		{Key: "PLAIN", Value: "x"},
+	}
+	got, err := formatTypedJSON(envVars)
+	if err != nil {
+		t.Fatalf("formatTypedJSON() error = %v", err)
+	}
+
+	var parsed map[string]struct{ Value, Type string }
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("formatTypedJSON() = %q, not valid JSON: %v", got, err)
+	}
+	for _, e := range envVars {
+		if p := parsed[e.Key]; p.Value != e.Value || ValueType(p.Type) != e.Type {
+			t.Errorf("%s = %+v, want %q %s", e.Key, p, e.Value, e.Type)
+		}
+	}
+}
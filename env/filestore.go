@@ -0,0 +1,135 @@
+package env
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileStore is a Store persisted as a JSON file holding both scopes.
+// A missing file is treated as an empty store.
+type FileStore struct {
+	filename string
+}
+
+type fileStoreData struct {
+	User   []EnvVar `json:"user"`
+	System []EnvVar `json:"system"`
+}
+
+// NewFileStore returns a FileStore backed by filename.
+func NewFileStore(filename string) *FileStore {
+	return &FileStore{filename: filename}
+}
+
+func (f *FileStore) List(scope Scope) ([]EnvVar, error) {
+	data, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	result := append([]EnvVar(nil), *data.scope(scope)...)
+	sortEnvVars(result)
+	return result, nil
+}
+
+func (f *FileStore) Get(scope Scope, key string) (EnvVar, bool, error) {
+	data, err := f.load()
+	if err != nil {
+		return EnvVar{}, false, err
+	}
+	vars := *data.scope(scope)
+	if i := indexOfVar(vars, key); i != -1 {
+		return vars[i], true, nil
+	}
+	return EnvVar{}, false, nil
+}
+
+func (f *FileStore) Set(scope Scope, v EnvVar) error {
+	data, err := f.load()
+	if err != nil {
+		return err
+	}
+	vars := data.scope(scope)
+	*vars = upsertVar(*vars, v)
+	return f.save(data)
+}
+
+func (f *FileStore) Delete(scope Scope, key string) error {
+	data, err := f.load()
+	if err != nil {
+		return err
+	}
+	vars := data.scope(scope)
+	var ok bool
+	if *vars, ok = removeVar(*vars, key); !ok {
+		return ErrNotFound
+	}
+	return f.save(data)
+}
+
+func (d *fileStoreData) scope(scope Scope) *[]EnvVar {
+	if scope == ScopeSystem {
+		return &d.System
+	}
+	return &d.User
+}
+
+func (f *FileStore) load() (*fileStoreData, error) {
+	var data fileStoreData
+	content, err := os.ReadFile(f.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return &data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("invalid store file %s: %w", f.filename, err)
+	}
+	return &data, nil
+}
+
+func (f *FileStore) save(data *fileStoreData) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(f.filename, content)
+}
+
+// writeFile replaces filename with data by writing a temporary file in the
+// same directory and renaming it over the target, so that a failed write
+// never leaves a truncated file behind. A symlinked target is replaced
+// through the link and an existing file keeps its permissions.
+func writeFile(filename string, data []byte) error {
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
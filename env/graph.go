@@ -0,0 +1,324 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Graph is the graph of references between the variables of both scopes.
+type Graph struct {
+	Nodes []GraphNode
+	conv  Conventions
+}
+
+// GraphNode is a variable and the references in its value.
+type GraphNode struct {
+	EnvVar
+	Scope Scope
+	Refs  []Reference
+}
+
+// Reference is one distinct variable reference in a value.
+type Reference struct {
+	Name string
+	// Target is the index in Graph.Nodes of the referenced variable, or -1
+	// when no stored variable of a visible scope has that name.
+	Target int
+	// External reports that an unstored name is set in the process
+	// environment, like USERPROFILE which Windows sets at logon.
+	External bool
+}
+
+// Dangling reports that the reference resolves to nothing.
+func (r Reference) Dangling() bool {
+	return r.Target == -1 && !r.External
+}
+
+// Label returns KEY [scope].
+func (n GraphNode) Label() string {
+	return fmt.Sprintf("%s [%s]", n.Key, n.Scope)
+}
+
+// BuildGraph builds the reference graph of the current store. System
+// values resolve against system variables; user values resolve against
+// user variables first and system variables second.
+func BuildGraph() (*Graph, error) {
+	system, err := current.List(ScopeSystem)
+	if err != nil && !errors.Is(err, errNoSystemScope) {
+		return nil, err
+	}
+	user, err := current.List(ScopeUser)
+	if err != nil {
+		return nil, err
+	}
+	return buildGraph(system, user, ConventionsOf(current), os.LookupEnv), nil
+}
+
+func buildGraph(system, user []EnvVar, conv Conventions, lookup func(string) (string, bool)) *Graph {
+	g := &Graph{Nodes: make([]GraphNode, 0, len(system)+len(user)), conv: conv}
+	for _, v := range system {
+		g.Nodes = append(g.Nodes, GraphNode{EnvVar: v, Scope: ScopeSystem})
+	}
+	for _, v := range user {
+		g.Nodes = append(g.Nodes, GraphNode{EnvVar: v, Scope: ScopeUser})
+	}
+
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		if n.Type == TypeString {
+			continue // REG_SZ values are never expanded
+		}
+		var seen []string
+		for _, ref := range conv.Reference.FindAllString(n.Value, -1) {
+			name := referenceName(ref)
+			if containsKey(seen, name, conv) {
+				continue
+			}
+			seen = append(seen, name)
+
+			r := Reference{Name: name, Target: g.resolve(name, n.Scope)}
+			if r.Target == -1 {
+				_, r.External = lookup(name)
+			}
+			n.Refs = append(n.Refs, r)
+		}
+	}
+	return g
+}
+
+// resolve returns the index of the variable name refers to from scope.
+func (g *Graph) resolve(name string, scope Scope) int {
+	if scope == ScopeUser {
+		if i := g.find(ScopeUser, name); i != -1 {
+			return i
+		}
+	}
+	return g.find(ScopeSystem, name)
+}
+
+// find returns the index of the variable key of scope, or -1.
+func (g *Graph) find(scope Scope, key string) int {
+	for i, n := range g.Nodes {
+		if n.Scope == scope && sameKey(n.Key, key, g.conv) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Dependents returns the indices of the nodes that reference node i.
+func (g *Graph) Dependents(i int) []int {
+	var result []int
+	for j, n := range g.Nodes {
+		for _, r := range n.Refs {
+			if r.Target == i {
+				result = append(result, j)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Dangling returns the indices of the nodes with dangling references.
+func (g *Graph) Dangling() []int {
+	var result []int
+	for i, n := range g.Nodes {
+		for _, r := range n.Refs {
+			if r.Dangling() {
+				result = append(result, i)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Cycles returns the reference cycles of the graph as node indices, the
+// first node repeated at the end, e.g. [A B A].
+func (g *Graph) Cycles() [][]int {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(g.Nodes))
+	var stack []int
+	var cycles [][]int
+
+	var visit func(i int)
+	visit = func(i int) {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, r := range g.Nodes[i].Refs {
+			switch {
+			case r.Target == -1:
+			case state[r.Target] == visiting:
+				for k := len(stack) - 1; k >= 0; k-- {
+					if stack[k] == r.Target {
+						cycle := append(append([]int(nil), stack[k:]...), r.Target)
+						cycles = append(cycles, cycle)
+						break
+					}
+				}
+			case state[r.Target] == unvisited:
+				visit(r.Target)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+	}
+
+	for i := range g.Nodes {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+	return cycles
+}
+
+// roots returns the nodes to print trees from: nodes with references that
+// nothing references, followed by nodes only reachable through a cycle.
+func (g *Graph) roots() []int {
+	referenced := make([]bool, len(g.Nodes))
+	for _, n := range g.Nodes {
+		for _, r := range n.Refs {
+			if r.Target != -1 {
+				referenced[r.Target] = true
+			}
+		}
+	}
+
+	var roots []int
+	reached := make([]bool, len(g.Nodes))
+	var mark func(i int)
+	mark = func(i int) {
+		if reached[i] {
+			return
+		}
+		reached[i] = true
+		for _, r := range g.Nodes[i].Refs {
+			if r.Target != -1 {
+				mark(r.Target)
+			}
+		}
+	}
+	for i, n := range g.Nodes {
+		if len(n.Refs) > 0 && !referenced[i] {
+			roots = append(roots, i)
+			mark(i)
+		}
+	}
+	for i, n := range g.Nodes {
+		if len(n.Refs) > 0 && !reached[i] {
+			roots = append(roots, i)
+			mark(i)
+		}
+	}
+	return roots
+}
+
+// WriteTree writes each variable that references others as a tree of its
+// references. Undefined names are marked (undefined), names only set in the
+// process environment (process), and references back into the current
+// path (cycle).
+func (g *Graph) WriteTree(w io.Writer) error {
+	var sb strings.Builder
+	for _, i := range g.roots() {
+		sb.WriteString(g.Nodes[i].Label() + "\n")
+		g.writeRefs(&sb, i, "", []int{i})
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (g *Graph) writeRefs(sb *strings.Builder, i int, indent string, path []int) {
+	refs := g.Nodes[i].Refs
+	for k, r := range refs {
+		branch, next := "├── ", "│   "
+		if k == len(refs)-1 {
+			branch, next = "└── ", "    "
+		}
+		sb.WriteString(indent + branch)
+
+		switch {
+		case r.Dangling():
+			sb.WriteString(r.Name + " (undefined)\n")
+		case r.External:
+			sb.WriteString(r.Name + " (process)\n")
+		case containsIndex(path, r.Target):
+			sb.WriteString(g.Nodes[r.Target].Label() + " (cycle)\n")
+		default:
+			sb.WriteString(g.Nodes[r.Target].Label() + "\n")
+			g.writeRefs(sb, r.Target, indent+next, append(path, r.Target))
+		}
+	}
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. Edges point from a
+// variable to the variables it references; dangling references point to
+// dashed red nodes.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph menv {\n")
+	sb.WriteString("\trankdir=LR;\n")
+	sb.WriteString("\tnode [shape=box];\n")
+
+	for i, n := range g.Nodes {
+		if len(n.Refs) == 0 && len(g.Dependents(i)) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\t%s;\n", dotID(n.Label()))
+	}
+
+	var external []string
+	for _, n := range g.Nodes {
+		for _, r := range n.Refs {
+			from := dotID(n.Label())
+			switch {
+			case r.Target != -1:
+				fmt.Fprintf(&sb, "\t%s -> %s;\n", from, dotID(g.Nodes[r.Target].Label()))
+			case r.External:
+				fmt.Fprintf(&sb, "\t%s -> %s;\n", from, dotID(r.Name))
+				external = appendUnique(external, r.Name)
+			default:
+				id := dotID(r.Name + " (undefined)")
+				fmt.Fprintf(&sb, "\t%s [style=dashed, color=red];\n", id)
+				fmt.Fprintf(&sb, "\t%s -> %s [style=dashed, color=red];\n", from, id)
+			}
+		}
+	}
+	for _, name := range external {
+		fmt.Fprintf(&sb, "\t%s [style=dotted];\n", dotID(name))
+	}
+
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// dotID quotes s as a DOT identifier.
+func dotID(s string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+func containsKey(keys []string, key string, conv Conventions) bool {
+	for _, k := range keys {
+		if sameKey(k, key, conv) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIndex(list []int, i int) bool {
+	for _, v := range list {
+		if v == i {
+			return true
+		}
+	}
+	return false
+}
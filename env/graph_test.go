@@ -0,0 +1,131 @@
+package env
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testGraph() *Graph {
+	system := []EnvVar{
+		{Key: "JAVA_HOME", Value: "C:\\jdk", Type: TypeString},
+		{Key: "Path", Value: "%SystemRoot%\\system32;%JAVA_HOME%\\bin", Type: TypeExpandString},
+		{Key: "A", Value: "%B%", Type: TypeExpandString},
+		{Key: "B", Value: "%a%", Type: TypeExpandString},
+	}
+	user := []EnvVar{
+		{Key: "MAVEN_HOME", Value: "%JAVA_HOME%\\..\\maven", Type: TypeExpandString},
+		{Key: "Path", Value: "%MAVEN_HOME%\\bin;%GOPATH%\\bin;%MAVEN_HOME%\\lib", Type: TypeExpandString},
+		{Key: "LITERAL", Value: "%NOT_A_REF%", Type: TypeString},
+	}
+	lookup := func(name string) (string, bool) { return "", name == "SystemRoot" }
+	return buildGraph(system, user, windowsConventions, lookup)
+}
+
+func TestBuildGraph(t *testing.T) {
+	g := testGraph()
+
+	userPath := g.Nodes[5]
+	want := []Reference{
+		{Name: "MAVEN_HOME", Target: 4},
+		{Name: "GOPATH", Target: -1},
+	}
+	if !reflect.DeepEqual(userPath.Refs, want) {
+		t.Errorf("user Path refs = %+v, want %+v", userPath.Refs, want)
+	}
+
+	systemPath := g.Nodes[1]
+	want = []Reference{
+		{Name: "SystemRoot", Target: -1, External: true},
+		{Name: "JAVA_HOME", Target: 0},
+	}
+	if !reflect.DeepEqual(systemPath.Refs, want) {
+		t.Errorf("system Path refs = %+v, want %+v", systemPath.Refs, want)
+	}
+
+	if refs := g.Nodes[6].Refs; refs != nil {
+		t.Errorf("REG_SZ refs = %+v, want none", refs)
+	}
+}
+
+func TestBuildGraph_SystemDoesNotSeeUser(t *testing.T) {
+	system := []EnvVar{{Key: "X", Value: "%ONLY_USER%", Type: TypeExpandString}}
+	user := []EnvVar{{Key: "ONLY_USER", Value: "u"}}
+	g := buildGraph(system, user, windowsConventions, func(string) (string, bool) { return "", false })
+
+	if r := g.Nodes[0].Refs[0]; !r.Dangling() {
+		t.Errorf("system reference to user variable = %+v, want dangling", r)
+	}
+}
+
+func TestGraph_Dependents(t *testing.T) {
+	g := testGraph()
+	if got, want := g.Dependents(0), []int{1, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dependents(JAVA_HOME) = %v, want %v", got, want)
+	}
+	if got := g.Dependents(5); got != nil {
+		t.Errorf("Dependents(Path) = %v, want none", got)
+	}
+}
+
+func TestGraph_DanglingAndCycles(t *testing.T) {
+	g := testGraph()
+	if got, want := g.Dangling(), []int{5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dangling() = %v, want %v", got, want)
+	}
+	if got, want := g.Cycles(), [][]int{{2, 3, 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Cycles() = %v, want %v", got, want)
+	}
+}
+
+func TestGraph_WriteTree(t *testing.T) {
+	var sb strings.Builder
+	if err := testGraph().WriteTree(&sb); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Path [system]
+├── SystemRoot (process)
+└── JAVA_HOME [system]
+Path [user]
+├── MAVEN_HOME [user]
+│   └── JAVA_HOME [system]
+└── GOPATH (undefined)
+A [system]
+└── B [system]
+    └── A [system] (cycle)
+`
+	if sb.String() != want {
+		t.Errorf("WriteTree() =\n%s\nwant\n%s", sb.String(), want)
+	}
+}
+
+func TestGraph_WriteDOT(t *testing.T) {
+	var sb strings.Builder
+	if err := testGraph().WriteDOT(&sb); err != nil {
+		t.Fatal(err)
+	}
+	got := sb.String()
+
+	for _, want := range []string{
+		"digraph menv {\n",
+		"\t\"MAVEN_HOME [user]\" -> \"JAVA_HOME [system]\";\n",
+		"\t\"Path [user]\" -> \"GOPATH (undefined)\" [style=dashed, color=red];\n",
+		"\t\"Path [system]\" -> \"SystemRoot\";\n",
+		"\t\"SystemRoot\" [style=dotted];\n",
+		"\t\"A [system]\" -> \"B [system]\";\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteDOT() missing %q in\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "LITERAL") {
+		t.Errorf("WriteDOT() includes unconnected variable:\n%s", got)
+	}
+}
+
+func TestDotID(t *testing.T) {
+	if got, want := dotID(`a "b" \c`), `"a \"b\" \\c"`; got != want {
+		t.Errorf("dotID() = %s, want %s", got, want)
+	}
+}
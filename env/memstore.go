@@ -0,0 +1,90 @@
+package env
+
+import (
+	"strings"
+	"sync"
+)
+
+// MemoryStore is a Store kept in memory, used for tests and previews.
+type MemoryStore struct {
+	mu   sync.Mutex
+	vars map[Scope][]EnvVar
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{vars: make(map[Scope][]EnvVar)}
+}
+
+func (m *MemoryStore) List(scope Scope) ([]EnvVar, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := append([]EnvVar(nil), m.vars[scope]...)
+	sortEnvVars(result)
+	return result, nil
+}
+
+func (m *MemoryStore) Get(scope Scope, key string) (EnvVar, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := indexOfVar(m.vars[scope], key); i != -1 {
+		return m.vars[scope][i], true, nil
+	}
+	return EnvVar{}, false, nil
+}
+
+func (m *MemoryStore) Set(scope Scope, v EnvVar) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.vars[scope] = upsertVar(m.vars[scope], v)
+	return nil
+}
+
+func (m *MemoryStore) Delete(scope Scope, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vars, ok := removeVar(m.vars[scope], key)
+	if !ok {
+		return ErrNotFound
+	}
+	m.vars[scope] = vars
+	return nil
+}
+
+func indexOfVar(vars []EnvVar, key string) int {
+	for i, v := range vars {
+		if strings.EqualFold(v.Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexOfExactVar is indexOfVar for case-sensitive stores.
+func indexOfExactVar(vars []EnvVar, key string) int {
+	for i, v := range vars {
+		if v.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// upsertVar replaces the variable with the same key (keeping the existing
+// key's casing, like the registry does) or appends a new one.
+func upsertVar(vars []EnvVar, v EnvVar) []EnvVar {
+	if i := indexOfVar(vars, v.Key); i != -1 {
+		v.Key = vars[i].Key
+		vars[i] = v
+		return vars
+	}
+	return append(vars, v)
+}
+
+func removeVar(vars []EnvVar, key string) ([]EnvVar, bool) {
+	i := indexOfVar(vars, key)
+	if i == -1 {
+		return vars, false
+	}
+	return append(vars[:i], vars[i+1:]...), true
+}
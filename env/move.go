@@ -0,0 +1,141 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/doraemonkeys/menv/color"
+)
+
+// Rename renames oldKey to newKey within scope, keeping its value and type.
+// An existing newKey is only overwritten with force. With rewrite, the
+// references to oldKey in other variables, PATH included, are changed to
+// reference newKey. The new key, the deletion and the rewrites are applied
+// as one Changeset, so a failure leaves every variable as it was. The old
+// key is only deleted if it still holds the value that was read.
+func Rename(scope Scope, oldKey, newKey string, force, rewrite bool) error {
+	conv := ConventionsOf(current)
+	v, ok, err := current.Get(scope, oldKey)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s: %w", oldKey, ErrNotFound)
+	}
+
+	renamed := v
+	renamed.Key = newKey
+	var c Changeset
+	caseOnly := sameKey(oldKey, newKey, conv)
+	if caseOnly {
+		// Stores with case-insensitive keys keep the existing spelling on
+		// Set, so the variable is deleted and written again.
+		c.DeleteIf(scope, v.Key, v.Value)
+		c.Set(scope, renamed)
+	} else {
+		target, exists, err := current.Get(scope, newKey)
+		if err != nil {
+			return err
+		}
+		if exists && !force {
+			return fmt.Errorf("%s already exists, use -force to overwrite it", newKey)
+		}
+		// The new key is written before the old one is deleted, so that
+		// the value is never missing.
+		c.SetIf(scope, renamed, target.Value)
+		c.DeleteIf(scope, v.Key, v.Value)
+	}
+
+	var rewritten []GraphNode
+	if rewrite {
+		deps, err := findDependents(scope, oldKey, false)
+		if err != nil {
+			return err
+		}
+		for _, d := range deps {
+			if d.Scope == scope && (sameKey(d.Key, oldKey, conv) || sameKey(d.Key, newKey, conv)) {
+				continue // the renamed variable itself, or the one it replaces
+			}
+			r := d.GraphNode
+			r.Value = renameReferences(d.Value, oldKey, newKey, conv)
+			c.SetIf(d.Scope, r.EnvVar, d.Value)
+			rewritten = append(rewritten, r)
+		}
+	}
+
+	if _, err := c.Apply(); err != nil {
+		return err
+	}
+	color.Done("rename %s -> %s%s", v.Key, newKey, scopeSuffix(scope))
+	for _, r := range rewritten {
+		color.Done("rewrite %s=%s%s", r.Key, r.Value, scopeSuffix(r.Scope))
+	}
+	return nil
+}
+
+// renameReferences changes the references to oldKey in value to newKey,
+// keeping their %NAME%, $NAME or ${NAME} form.
+func renameReferences(value, oldKey, newKey string, conv Conventions) string {
+	return replaceReferences(value, oldKey, conv, func(ref string) string {
+		return strings.Replace(ref, referenceName(ref), newKey, 1)
+	})
+}
+
+// Promote moves key from the user scope to the system scope, or copies it
+// with keep. A system variable with a different value or type is only
+// overwritten with force.
+func Promote(key string, keep, force bool) error {
+	return transfer(key, ScopeUser, ScopeSystem, keep, force)
+}
+
+// Demote moves key from the system scope to the user scope, or copies it
+// with keep. A user variable with a different value or type is only
+// overwritten with force.
+func Demote(key string, keep, force bool) error {
+	return transfer(key, ScopeSystem, ScopeUser, keep, force)
+}
+
+// transfer writes the destination with SetIf on the value checked for a
+// conflict, so that a variable changed in between is never overwritten,
+// and deletes the source in the same Changeset if it still holds the value
+// that was copied. Variables like PATH, whose user value is appended to the
+// system one, are refused: their entries are moved with path.Transfer.
+func transfer(key string, from, to Scope, keep, force bool) error {
+	if IsConcatenated(key, ConventionsOf(current)) {
+		return fmt.Errorf("the user %s is appended to the system one, use -path to move its entries", key)
+	}
+	v, ok, err := current.Get(from, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s %s: %w", from, key, ErrNotFound)
+	}
+
+	dest, exists, err := current.Get(to, key)
+	if err != nil {
+		return err
+	}
+	same := exists && dest.Value == v.Value && dest.Type == v.Type
+	if exists && !same && !force {
+		return fmt.Errorf("%s %s differs: %s (use -force to overwrite it)", to, dest.Key, dest.Value)
+	}
+
+	var c Changeset
+	if !same {
+		c.SetIf(to, v, dest.Value)
+	}
+	if !keep {
+		c.DeleteIf(from, v.Key, v.Value)
+	}
+	if _, err := c.Apply(); err != nil {
+		return err
+	}
+
+	verb := "move"
+	if keep {
+		verb = "copy"
+	}
+	color.Done("%s %s=%s %s -> %s", verb, v.Key, v.Value, from, to)
+	return nil
+}
@@ -0,0 +1,322 @@
+package env
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		force   bool
+		rewrite bool
+		want    []EnvVar
+		wantErr bool
+	}{
+		{
+			name: "keeps value and type",
+			old:  "MAVEN_HOME", new: "M2_HOME",
+			want: []EnvVar{
+				{Key: "JAVA_HOME", Value: "C:\\jdk", Type: TypeString},
+				{Key: "M2", Value: "%MAVEN_HOME%\\bin", Type: TypeExpandString},
+				{Key: "M2_HOME", Value: "%JAVA_HOME%\\..\\maven", Type: TypeExpandString},
+				{Key: "Path", Value: "C:\\bin;%java_home%\\bin;%MAVEN_HOME%\\bin;%JAVA_HOME%\\jre\\bin", Type: TypeExpandString},
+			},
+		},
+		{
+			name: "rewrites references",
+			old:  "JAVA_HOME", new: "JDK", rewrite: true,
+			want: []EnvVar{
+				{Key: "JDK", Value: "C:\\jdk", Type: TypeString},
+				{Key: "M2", Value: "%MAVEN_HOME%\\bin", Type: TypeExpandString},
+				{Key: "MAVEN_HOME", Value: "%JDK%\\..\\maven", Type: TypeExpandString},
+				{Key: "Path", Value: "C:\\bin;%JDK%\\bin;%MAVEN_HOME%\\bin;%JDK%\\jre\\bin", Type: TypeExpandString},
+			},
+		},
+		{
+			name: "existing target refused",
+			old:  "M2", new: "JAVA_HOME",
+			wantErr: true,
+		},
+		{
+			name: "existing target forced",
+			old:  "M2", new: "JAVA_HOME", force: true,
+			want: []EnvVar{
+				{Key: "JAVA_HOME", Value: "%MAVEN_HOME%\\bin", Type: TypeExpandString},
+				{Key: "MAVEN_HOME", Value: "%JAVA_HOME%\\..\\maven", Type: TypeExpandString},
+				{Key: "Path", Value: "C:\\bin;%java_home%\\bin;%MAVEN_HOME%\\bin;%JAVA_HOME%\\jre\\bin", Type: TypeExpandString},
+			},
+		},
+		{
+			name: "case only",
+			old:  "M2", new: "m2",
+			want: []EnvVar{
+				{Key: "JAVA_HOME", Value: "C:\\jdk", Type: TypeString},
+				{Key: "m2", Value: "%MAVEN_HOME%\\bin", Type: TypeExpandString},
+				{Key: "MAVEN_HOME", Value: "%JAVA_HOME%\\..\\maven", Type: TypeExpandString},
+				{Key: "Path", Value: "C:\\bin;%java_home%\\bin;%MAVEN_HOME%\\bin;%JAVA_HOME%\\jre\\bin", Type: TypeExpandString},
+			},
+		},
+		{
+			name: "missing",
+			old:  "NOPE", new: "X",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := useDependentsStore(t)
+			before, _ := s.List(ScopeUser)
+
+			err := Rename(ScopeUser, tt.old, tt.new, tt.force, tt.rewrite)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Rename() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, _ := s.List(ScopeUser)
+			if tt.wantErr {
+				if !reflect.DeepEqual(got, before) {
+					t.Errorf("user vars = %v after failed rename, want unchanged", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("user vars = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRename_NotFound(t *testing.T) {
+	useDependentsStore(t)
+	if err := Rename(ScopeSystem, "JAVA_HOME", "JDK", false, false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Rename() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRename_ConflictAfterRead(t *testing.T) {
+	jdk := EnvVar{Key: "JAVA_HOME", Value: "C:\\jdk", Type: TypeString}
+	race := EnvVar{Key: "JAVA_HOME", Value: "C:\\other", Type: TypeString}
+	s := &racingStore{MemoryStore: NewMemoryStore(), race: race}
+	_ = s.MemoryStore.Set(ScopeSystem, jdk)
+	prev := CurrentStore()
+	UseStore(s)
+	t.Cleanup(func() { UseStore(prev) })
+
+	if err := Rename(ScopeSystem, "JAVA_HOME", "JDK", false, false); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Rename() error = %v, want ErrConflict", err)
+	}
+	if got, _, _ := s.Get(ScopeSystem, "JAVA_HOME"); got != race {
+		t.Errorf("JAVA_HOME = %v, want the concurrent write kept", got)
+	}
+	if _, ok, _ := s.Get(ScopeSystem, "JDK"); ok {
+		t.Error("JDK is set after a conflicting rename")
+	}
+}
+
+func TestRename_RollBackOnFailure(t *testing.T) {
+	// Writing JDK, deleting JAVA_HOME, rewriting MAVEN_HOME and Path.
+	for n := 1; n <= 4; n++ {
+		s := useDependentsStore(t)
+		before, _ := s.List(ScopeUser)
+		UseStore(&failingStore{Store: s, n: n})
+
+		if err := Rename(ScopeUser, "JAVA_HOME", "JDK", false, true); !errors.Is(err, errWriteFailed) {
+			t.Fatalf("Rename() error = %v, want the failed write", err)
+		}
+		if after, _ := s.List(ScopeUser); !reflect.DeepEqual(after, before) {
+			t.Errorf("write %d failed: user vars = %v, want %v", n, after, before)
+		}
+	}
+}
+
+func TestRenameReferences(t *testing.T) {
+	tests := []struct {
+		value string
+		conv  Conventions
+		want  string
+	}{
+		{"%old%\\bin;%OLDER%", windowsConventions, "%NEW%\\bin;%OLDER%"},
+		{"$OLD/bin:${OLD}:$OLDER:$old", posixConventions, "$NEW/bin:${NEW}:$OLDER:$old"},
+	}
+	for _, tt := range tests {
+		if got := renameReferences(tt.value, "OLD", "NEW", tt.conv); got != tt.want {
+			t.Errorf("renameReferences(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPromoteDemote(t *testing.T) {
+	jdk := EnvVar{Key: "JAVA_HOME", Value: "C:\\jdk", Type: TypeString}
+	other := EnvVar{Key: "JAVA_HOME", Value: "C:\\old", Type: TypeString}
+
+	tests := []struct {
+		name        string
+		user        []EnvVar
+		system      []EnvVar
+		demote      bool
+		keep, force bool
+		wantUser    []EnvVar
+		wantSystem  []EnvVar
+		wantErr     bool
+	}{
+		{name: "promote moves", user: []EnvVar{jdk}, wantSystem: []EnvVar{jdk}},
+		{name: "promote keep copies", user: []EnvVar{jdk}, keep: true, wantUser: []EnvVar{jdk}, wantSystem: []EnvVar{jdk}},
+		{name: "demote moves", system: []EnvVar{jdk}, demote: true, wantUser: []EnvVar{jdk}},
+		{name: "same value at destination", user: []EnvVar{jdk}, system: []EnvVar{jdk}, wantSystem: []EnvVar{jdk}},
+		{name: "conflict", user: []EnvVar{jdk}, system: []EnvVar{other}, wantErr: true},
+		{name: "conflict forced", user: []EnvVar{jdk}, system: []EnvVar{other}, force: true, wantSystem: []EnvVar{jdk}},
+		{
+			name: "type conflict", user: []EnvVar{jdk},
+			system:  []EnvVar{{Key: "JAVA_HOME", Value: "C:\\jdk", Type: TypeExpandString}},
+			wantErr: true,
+		},
+		{name: "missing source", system: []EnvVar{jdk}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore()
+			prev := CurrentStore()
+			UseStore(s)
+			t.Cleanup(func() { UseStore(prev) })
+			for _, v := range tt.user {
+				_ = s.Set(ScopeUser, v)
+			}
+			for _, v := range tt.system {
+				_ = s.Set(ScopeSystem, v)
+			}
+
+			var err error
+			if tt.demote {
+				err = Demote("java_home", tt.keep, tt.force)
+			} else {
+				err = Promote("java_home", tt.keep, tt.force)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			wantUser, wantSystem := tt.wantUser, tt.wantSystem
+			if tt.wantErr {
+				wantUser, wantSystem = tt.user, tt.system
+			}
+			if got, _ := s.List(ScopeUser); !sameVars(got, wantUser) {
+				t.Errorf("user = %v, want %v", got, wantUser)
+			}
+			if got, _ := s.List(ScopeSystem); !sameVars(got, wantSystem) {
+				t.Errorf("system = %v, want %v", got, wantSystem)
+			}
+		})
+	}
+}
+
+// racingStore sets race in the system scope right after the first system
+// Get, like another process writing between menv's check and its write.
+type racingStore struct {
+	*MemoryStore
+	race EnvVar
+	done bool
+}
+
+func (s *racingStore) Get(scope Scope, key string) (EnvVar, bool, error) {
+	v, ok, err := s.MemoryStore.Get(scope, key)
+	if scope == ScopeSystem && !s.done {
+		s.done = true
+		_ = s.MemoryStore.Set(ScopeSystem, s.race)
+	}
+	return v, ok, err
+}
+
+func TestPromote_ConflictAfterCheck(t *testing.T) {
+	jdk := EnvVar{Key: "JAVA_HOME", Value: "C:\\jdk", Type: TypeString}
+	race := EnvVar{Key: "JAVA_HOME", Value: "C:\\other", Type: TypeString}
+	s := &racingStore{MemoryStore: NewMemoryStore(), race: race}
+	_ = s.Set(ScopeUser, jdk)
+	prev := CurrentStore()
+	UseStore(s)
+	t.Cleanup(func() { UseStore(prev) })
+
+	if err := Promote("JAVA_HOME", false, false); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Promote() error = %v, want ErrConflict", err)
+	}
+	if got, _, _ := s.Get(ScopeSystem, "JAVA_HOME"); got != race {
+		t.Errorf("system JAVA_HOME = %v, want the concurrent write kept", got)
+	}
+	if got, _, _ := s.Get(ScopeUser, "JAVA_HOME"); got != jdk {
+		t.Errorf("user JAVA_HOME = %v, want it kept", got)
+	}
+}
+
+func TestDemote_SourceChangedAfterRead(t *testing.T) {
+	jdk := EnvVar{Key: "JAVA_HOME", Value: "C:\\jdk", Type: TypeString}
+	race := EnvVar{Key: "JAVA_HOME", Value: "C:\\other", Type: TypeString}
+	s := &racingStore{MemoryStore: NewMemoryStore(), race: race}
+	_ = s.MemoryStore.Set(ScopeSystem, jdk)
+	prev := CurrentStore()
+	UseStore(s)
+	t.Cleanup(func() { UseStore(prev) })
+
+	if err := Demote("JAVA_HOME", false, false); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Demote() error = %v, want ErrConflict", err)
+	}
+	if got, _, _ := s.Get(ScopeSystem, "JAVA_HOME"); got != race {
+		t.Errorf("system JAVA_HOME = %v, want the concurrent write kept", got)
+	}
+	if _, ok, _ := s.Get(ScopeUser, "JAVA_HOME"); ok {
+		t.Error("user JAVA_HOME is set after a conflicting move")
+	}
+}
+
+func TestPromote_ConcatenatedRefused(t *testing.T) {
+	userPath := EnvVar{Key: "Path", Value: "C:\\tools", Type: TypeExpandString}
+	systemPath := EnvVar{Key: "Path", Value: "C:\\Windows", Type: TypeExpandString}
+	s := NewMemoryStore()
+	_ = s.Set(ScopeUser, userPath)
+	_ = s.Set(ScopeSystem, systemPath)
+	prev := CurrentStore()
+	UseStore(s)
+	t.Cleanup(func() { UseStore(prev) })
+
+	for _, key := range []string{"Path", "PATH"} {
+		if err := Promote(key, false, true); err == nil {
+			t.Errorf("Promote(%s, force) succeeded, want it refused", key)
+		}
+		if err := Demote(key, true, true); err == nil {
+			t.Errorf("Demote(%s, keep, force) succeeded, want it refused", key)
+		}
+	}
+	if got, _, _ := s.Get(ScopeUser, "Path"); got != userPath {
+		t.Errorf("user Path = %v, want it kept", got)
+	}
+	if got, _, _ := s.Get(ScopeSystem, "Path"); got != systemPath {
+		t.Errorf("system Path = %v, want it kept", got)
+	}
+}
+
+func TestPromote_RollBackOnFailure(t *testing.T) {
+	jdk := EnvVar{Key: "JAVA_HOME", Value: "C:\\jdk", Type: TypeString}
+	s := NewMemoryStore()
+	_ = s.Set(ScopeUser, jdk)
+	prev := CurrentStore()
+	UseStore(&failingStore{Store: s, n: 2})
+	t.Cleanup(func() { UseStore(prev) })
+
+	if err := Promote("JAVA_HOME", false, false); !errors.Is(err, errWriteFailed) {
+		t.Fatalf("Promote() error = %v, want the failed delete", err)
+	}
+	if _, ok, _ := s.Get(ScopeSystem, "JAVA_HOME"); ok {
+		t.Error("system JAVA_HOME is set after a failed move, want it rolled back")
+	}
+	if got, _, _ := s.Get(ScopeUser, "JAVA_HOME"); got != jdk {
+		t.Errorf("user JAVA_HOME = %v, want it kept", got)
+	}
+}
+
+// sameVars compares variable lists, treating nil and empty alike.
+func sameVars(a, b []EnvVar) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package env
+
+// defaultStore returns the shell profile store.
+func defaultStore() Store {
+	return defaultProfileStore()
+}
+
+// broadcastEnvChange is a no-op: only Windows caches the environment in running programs.
+func broadcastEnvChange() {}
+
+// regList fails: there is no registry to read.
+func regList(Scope) ([]EnvVar, error) {
+	return nil, errNoRegistry
+}
+
+func regSet(Scope, EnvVar) error {
+	return errNoRegistry
+}
+
+func regDelete(Scope, string) error {
+	return errNoRegistry
+}
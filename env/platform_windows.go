@@ -0,0 +1,146 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+var procSendMessageTimeout = windows.NewLazySystemDLL("user32.dll").NewProc("SendMessageTimeoutW")
+
+const (
+	hwndBroadcast   = 0xffff
+	wmSettingChange = 0x001A
+	smtoAbortIfHung = 0x0002
+)
+
+// defaultStore returns the live registry.
+func defaultStore() Store {
+	return RegistryStore{}
+}
+
+// broadcastEnvChange notifies running programs such as Explorer that the
+// environment changed, as SetEnvironmentVariable does.
+func broadcastEnvChange() {
+	param, err := windows.UTF16PtrFromString("Environment")
+	if err != nil {
+		return
+	}
+	var result uintptr
+	_, _, _ = procSendMessageTimeout.Call(hwndBroadcast, wmSettingChange, 0,
+		uintptr(unsafe.Pointer(param)), smtoAbortIfHung, 5000, uintptr(unsafe.Pointer(&result)))
+}
+
+// openEnvKey opens the registry key holding the variables of scope.
+func openEnvKey(scope Scope, access uint32) (registry.Key, error) {
+	if scope == ScopeSystem {
+		return registry.OpenKey(registry.LOCAL_MACHINE, systemEnvKey, access)
+	}
+	return registry.OpenKey(registry.CURRENT_USER, userEnvKey, access)
+}
+
+// regList reads all named values of the scope's key.
+func regList(scope Scope) ([]EnvVar, error) {
+	k, err := openEnvKey(scope, registry.QUERY_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Close()
+
+	names, err := k.ReadValueNames(0)
+	if err != nil {
+		return nil, err
+	}
+	vars := make([]EnvVar, 0, len(names))
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		typ, data, err := readRegValue(k, name)
+		if errors.Is(err, registry.ErrNotExist) {
+			// Deleted since the names were read.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, decodeRegValue(name, typ, data))
+	}
+	return vars, nil
+}
+
+// readRegValue returns the type and raw data of a value, growing the
+// buffer if the value grows while it is read.
+func readRegValue(k registry.Key, name string) (uint32, []byte, error) {
+	n, _, err := k.GetValue(name, nil)
+	for err == nil {
+		buf := make([]byte, n)
+		var typ uint32
+		n, typ, err = k.GetValue(name, buf)
+		if err == nil {
+			return typ, buf[:n], nil
+		}
+		if errors.Is(err, registry.ErrShortBuffer) {
+			err = nil
+		}
+	}
+	return 0, nil, err
+}
+
+// regSet writes v with its value type; checkRegValue has accepted it.
+func regSet(scope Scope, v EnvVar) error {
+	k, err := openEnvKey(scope, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+
+	switch v.Type {
+	case TypeString:
+		return k.SetStringValue(v.Key, v.Value)
+	case TypeExpandString:
+		return k.SetExpandStringValue(v.Key, v.Value)
+	case TypeMultiString:
+		return k.SetStringsValue(v.Key, multiStringItems(v.Value))
+	case TypeDWord:
+		n, err := regNumber(v)
+		if err != nil {
+			return err
+		}
+		return k.SetDWordValue(v.Key, uint32(n))
+	case TypeQWord:
+		n, err := regNumber(v)
+		if err != nil {
+			return err
+		}
+		return k.SetQWordValue(v.Key, n)
+	case TypeBinary:
+		b, err := regBytes(v)
+		if err != nil {
+			return err
+		}
+		return k.SetBinaryValue(v.Key, b)
+	default:
+		return fmt.Errorf("%s values cannot be written", v.Type)
+	}
+}
+
+// regDelete removes a value, returning ErrNotFound if there is none.
+func regDelete(scope Scope, key string) error {
+	k, err := openEnvKey(scope, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+
+	if err := k.DeleteValue(key); err != nil {
+		if errors.Is(err, registry.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
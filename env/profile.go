@@ -0,0 +1,367 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	profileBlockBegin  = "# >>> menv >>>"
+	profileBlockEnd    = "# <<< menv <<<"
+	profileBlockHeader = "# Managed by menv; edits inside this block may be overwritten."
+
+	// inheritedPath prefixes the user PATH so that, like on Windows, user
+	// entries are appended to the PATH inherited from the system.
+	inheritedPath = `"$PATH"`
+
+	systemEnvironmentFile = "/etc/environment"
+)
+
+// ProfileStore is a Store for Linux that keeps variables inside a
+// menv-managed block of a shell profile (user scope) and of /etc/environment
+// (system scope). Content outside the block is never modified.
+//
+// In the profile, variables are `export KEY=...` lines: REG_SZ values are
+// single-quoted and REG_EXPAND_SZ values double-quoted, so $VAR references
+// keep expanding. The user PATH holds only the user's entries, is appended
+// to the inherited PATH and is always double-quoted, so entries like
+// $HOME/bin expand.
+//
+// /etc/environment is read by pam_env, which neither runs a shell nor
+// expands $VAR, so system variables are plain KEY=value lines and values
+// that pam_env would not read back unchanged are rejected. Variables
+// defined outside the block are read too; writes still go to the block,
+// which comes last and therefore wins.
+type ProfileStore struct {
+	UserFile   string
+	SystemFile string
+}
+
+// NewProfileStore returns a ProfileStore for the given files.
+func NewProfileStore(userFile, systemFile string) *ProfileStore {
+	return &ProfileStore{UserFile: userFile, SystemFile: systemFile}
+}
+
+// defaultProfileStore returns a ProfileStore for ~/.profile and /etc/environment.
+func defaultProfileStore() *ProfileStore {
+	userFile := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		userFile = filepath.Join(home, ".profile")
+	}
+	return NewProfileStore(userFile, systemEnvironmentFile)
+}
+
+func (p *ProfileStore) Conventions() Conventions {
+	return posixConventions
+}
+
+func (p *ProfileStore) List(scope Scope) ([]EnvVar, error) {
+	f, err := p.load(scope)
+	if err != nil {
+		return nil, err
+	}
+	result := f.visible()
+	sortEnvVars(result)
+	return result, nil
+}
+
+func (p *ProfileStore) Get(scope Scope, key string) (EnvVar, bool, error) {
+	f, err := p.load(scope)
+	if err != nil {
+		return EnvVar{}, false, err
+	}
+	vars := f.visible()
+	if i := indexOfExactVar(vars, key); i != -1 {
+		return vars[i], true, nil
+	}
+	return EnvVar{}, false, nil
+}
+
+func (p *ProfileStore) Set(scope Scope, v EnvVar) error {
+	if scope == ScopeSystem {
+		if err := checkPamEnvValue(v); err != nil {
+			return err
+		}
+	}
+	f, err := p.load(scope)
+	if err != nil {
+		return err
+	}
+	if i := f.index(v.Key); i != -1 {
+		f.block[i] = v
+	} else {
+		f.block = append(f.block, v)
+	}
+	return f.save()
+}
+
+func (p *ProfileStore) Delete(scope Scope, key string) error {
+	f, err := p.load(scope)
+	if err != nil {
+		return err
+	}
+	i := f.index(key)
+	if i == -1 {
+		if f.outsideIndex(key) != -1 {
+			return fmt.Errorf("%s is defined outside the menv block of %s", key, f.filename)
+		}
+		return ErrNotFound
+	}
+	f.block = append(f.block[:i], f.block[i+1:]...)
+	return f.save()
+}
+
+// profileFile is a parsed profile: the lines around the menv block and the
+// variables inside it.
+type profileFile struct {
+	filename string
+	scope    Scope
+	before   []string
+	after    []string
+	block    []EnvVar
+	hasBlock bool
+	// outside holds variables defined outside the block (system scope only).
+	outside []EnvVar
+}
+
+func (p *ProfileStore) load(scope Scope) (*profileFile, error) {
+	filename := p.UserFile
+	if scope == ScopeSystem {
+		filename = p.SystemFile
+	}
+	if filename == "" {
+		return nil, fmt.Errorf("no profile file configured for %s scope", scope)
+	}
+
+	f := &profileFile{filename: filename, scope: scope}
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.parse(string(content))
+	return f, nil
+}
+
+func (f *profileFile) parse(content string) {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	begin, end := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case profileBlockBegin:
+			if begin == -1 {
+				begin = i
+			}
+		case profileBlockEnd:
+			if begin != -1 && end == -1 {
+				end = i
+			}
+		}
+	}
+
+	if begin == -1 || end == -1 {
+		f.before = lines
+		if content == "" {
+			f.before = nil
+		}
+	} else {
+		f.hasBlock = true
+		f.before = lines[:begin]
+		f.after = lines[end+1:]
+		for _, line := range lines[begin+1 : end] {
+			if v, ok := f.parseLine(line); ok {
+				f.block = append(f.block, v)
+			}
+		}
+	}
+
+	if f.scope == ScopeSystem {
+		for _, line := range append(append([]string(nil), f.before...), f.after...) {
+			if v, ok := f.parseLine(line); ok {
+				f.outside = append(f.outside, v)
+			}
+		}
+	}
+}
+
+// parseLine parses `export KEY=VALUE` or `KEY=VALUE`, the way the shell
+// does in the profile and the way pam_env does in /etc/environment.
+func (f *profileFile) parseLine(line string) (EnvVar, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return EnvVar{}, false
+	}
+	line = strings.TrimPrefix(line, "export ")
+	key, raw, ok := strings.Cut(line, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" || strings.ContainsAny(key, " \t") {
+		return EnvVar{}, false
+	}
+
+	if f.scope == ScopeSystem {
+		return EnvVar{Key: key, Value: unquotePamEnv(raw), Type: TypeString}, true
+	}
+	if f.isUserPath(key) {
+		raw = strings.TrimPrefix(raw, inheritedPath)
+		raw = strings.TrimPrefix(raw, posixConventions.ListSeparator)
+	}
+	value, typ := unquoteShell(raw)
+	if f.isUserPath(key) {
+		typ = TypeExpandString
+	}
+	return EnvVar{Key: key, Value: value, Type: typ}, true
+}
+
+func (f *profileFile) isUserPath(key string) bool {
+	return f.scope == ScopeUser && key == posixConventions.PathKey
+}
+
+// visible returns the block variables followed by outside variables they
+// do not override.
+func (f *profileFile) visible() []EnvVar {
+	result := append([]EnvVar(nil), f.block...)
+	for i, v := range f.outside {
+		if f.index(v.Key) == -1 && f.outsideIndex(v.Key) == i {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func (f *profileFile) index(key string) int {
+	return indexOfExactVar(f.block, key)
+}
+
+// outsideIndex returns the last outside definition of key, which is the
+// one that takes effect.
+func (f *profileFile) outsideIndex(key string) int {
+	for i := len(f.outside) - 1; i >= 0; i-- {
+		if f.outside[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *profileFile) save() error {
+	lines := append([]string(nil), f.before...)
+	if len(f.block) > 0 {
+		// Separate a newly appended block from the existing content
+		if !f.hasBlock && len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, profileBlockBegin, profileBlockHeader)
+		for _, v := range f.block {
+			lines = append(lines, f.formatLine(v))
+		}
+		lines = append(lines, profileBlockEnd)
+	}
+	lines = append(lines, f.after...)
+
+	content := ""
+	if len(lines) > 0 {
+		content = strings.Join(lines, "\n") + "\n"
+	}
+	return writeFile(f.filename, []byte(content))
+}
+
+func (f *profileFile) formatLine(v EnvVar) string {
+	if f.scope == ScopeSystem {
+		return v.Key + "=" + v.Value
+	}
+	value := quoteShell(v.Value, v.Type)
+	if f.isUserPath(v.Key) {
+		value = inheritedPath
+		if v.Value != "" {
+			value += posixConventions.ListSeparator + quoteShell(v.Value, TypeExpandString)
+		}
+	}
+	return "export " + v.Key + "=" + value
+}
+
+// checkPamEnvValue rejects values that a plain KEY=value line of
+// /etc/environment cannot hold.
+func checkPamEnvValue(v EnvVar) error {
+	switch {
+	case v.Type == TypeExpandString || shellReference.MatchString(v.Value):
+		return fmt.Errorf("cannot set %s in %s: pam_env does not expand $ references", v.Key, systemEnvironmentFile)
+	case strings.ContainsAny(v.Value, "\r\n") || unquotePamEnv(v.Value) != v.Value:
+		return fmt.Errorf("cannot set %s in %s: pam_env would not read %q back unchanged", v.Key, systemEnvironmentFile, v.Value)
+	}
+	return nil
+}
+
+// unquotePamEnv returns the value of a KEY=value line as pam_env reads it:
+// trimmed, without a pair of surrounding quotes.
+func unquotePamEnv(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if len(raw) >= 2 && (raw[0] == '"' || raw[0] == '\'') && raw[len(raw)-1] == raw[0] {
+		return raw[1 : len(raw)-1]
+	}
+	return raw
+}
+
+// quoteShell quotes value for a POSIX shell: REG_EXPAND_SZ values are
+// double-quoted so that $VAR references expand, with every other $ escaped
+// so that $(cmd) is not run; others are single-quoted.
+func quoteShell(value string, typ ValueType) string {
+	if typ == TypeExpandString {
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`)
+		var sb strings.Builder
+		sb.WriteByte('"')
+		last := 0
+		for _, ref := range shellReference.FindAllStringIndex(value, -1) {
+			sb.WriteString(r.Replace(value[last:ref[0]]))
+			sb.WriteString(value[ref[0]:ref[1]])
+			last = ref[1]
+		}
+		sb.WriteString(r.Replace(value[last:]))
+		sb.WriteByte('"')
+		return sb.String()
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// unquoteShell reverses quoteShell, also accepting unquoted words. Values
+// containing an unescaped '$' outside single quotes are REG_EXPAND_SZ.
+func unquoteShell(raw string) (string, ValueType) {
+	var sb strings.Builder
+	typ := TypeString
+	raw = strings.TrimSpace(raw)
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; c {
+		case '\'':
+			end := strings.IndexByte(raw[i+1:], '\'')
+			if end == -1 {
+				end = len(raw) - i - 1
+			}
+			sb.WriteString(raw[i+1 : i+1+end])
+			i += end + 1
+		case '"':
+			for i++; i < len(raw) && raw[i] != '"'; i++ {
+				if raw[i] == '\\' && i+1 < len(raw) && strings.IndexByte("\\\"$`", raw[i+1]) != -1 {
+					i++
+				} else if raw[i] == '$' {
+					typ = TypeExpandString
+				}
+				sb.WriteByte(raw[i])
+			}
+		case '\\':
+			if i+1 < len(raw) {
+				i++
+			}
+			sb.WriteByte(raw[i])
+		case '$':
+			typ = TypeExpandString
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), typ
+}
@@ -0,0 +1,325 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestProfileStore(t *testing.T, userContent, systemContent string) *ProfileStore {
+	t.Helper()
+	dir := t.TempDir()
+	s := NewProfileStore(filepath.Join(dir, ".profile"), filepath.Join(dir, "environment"))
+	for filename, content := range map[string]string{s.UserFile: userContent, s.SystemFile: systemContent} {
+		if content == "" {
+			continue
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func readFile(t *testing.T, filename string) string {
+	t.Helper()
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestProfileStore_SetKeepsOutsideContent(t *testing.T) {
+	userProfile := "# ~/.profile\nif [ -d \"$HOME/bin\" ] ; then\n    PATH=\"$HOME/bin:$PATH\"\nfi\n"
+	s := newTestProfileStore(t, userProfile, "")
+
+	if err := s.Set(ScopeUser, EnvVar{Key: "JAVA_HOME", Value: "/opt/java", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := s.Set(ScopeUser, EnvVar{Key: "GOBIN", Value: "$HOME/go/bin", Type: TypeExpandString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	want := userProfile + "\n" + profileBlockBegin + "\n" + profileBlockHeader + "\n" +
+		"export JAVA_HOME='/opt/java'\n" +
+		"export GOBIN=\"$HOME/go/bin\"\n" +
+		profileBlockEnd + "\n"
+	if got := readFile(t, s.UserFile); got != want {
+		t.Errorf("profile =\n%s\nwant\n%s", got, want)
+	}
+
+	// Updating and deleting rewrites only the block.
+	if err := s.Set(ScopeUser, EnvVar{Key: "JAVA_HOME", Value: "/opt/jdk", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := s.Delete(ScopeUser, "GOBIN"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	want = userProfile + "\n" + profileBlockBegin + "\n" + profileBlockHeader + "\n" +
+		"export JAVA_HOME='/opt/jdk'\n" + profileBlockEnd + "\n"
+	if got := readFile(t, s.UserFile); got != want {
+		t.Errorf("profile =\n%s\nwant\n%s", got, want)
+	}
+
+	// Removing the last variable removes the block.
+	if err := s.Delete(ScopeUser, "JAVA_HOME"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got := readFile(t, s.UserFile); got != userProfile+"\n" {
+		t.Errorf("profile = %q, want %q", got, userProfile+"\n")
+	}
+}
+
+func TestProfileStore_ListOnlyBlockForUser(t *testing.T) {
+	content := "export OUTSIDE=1\n" + profileBlockBegin + "\nexport INSIDE='a b'\n" + profileBlockEnd + "\nexport AFTER=2\n"
+	s := newTestProfileStore(t, content, "")
+
+	got, err := s.List(ScopeUser)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(got) != 1 || got[0] != (EnvVar{Key: "INSIDE", Value: "a b", Type: TypeString}) {
+		t.Errorf("List() = %v, want [{INSIDE a b REG_SZ}]", got)
+	}
+
+	if err := s.Set(ScopeUser, EnvVar{Key: "NEW", Value: "x", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	got2 := readFile(t, s.UserFile)
+	if !strings.HasPrefix(got2, "export OUTSIDE=1\n"+profileBlockBegin) || !strings.HasSuffix(got2, profileBlockEnd+"\nexport AFTER=2\n") {
+		t.Errorf("profile = %q, want block rewritten in place", got2)
+	}
+}
+
+func TestProfileStore_UserPathAppendsToInherited(t *testing.T) {
+	s := newTestProfileStore(t, "", "")
+
+	if err := s.Set(ScopeUser, EnvVar{Key: "PATH", Value: "/opt/a:/opt/b", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got := readFile(t, s.UserFile); !strings.Contains(got, "export PATH=\"$PATH\":\"/opt/a:/opt/b\"\n") {
+		t.Errorf("profile = %q, want PATH appended to $PATH", got)
+	}
+
+	v, ok, err := s.Get(ScopeUser, "PATH")
+	if err != nil || !ok || v.Value != "/opt/a:/opt/b" {
+		t.Errorf("Get(PATH) = %v, %v, %v, want /opt/a:/opt/b", v, ok, err)
+	}
+
+	if err := s.Set(ScopeUser, EnvVar{Key: "PATH", Value: "", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if v, _, _ := s.Get(ScopeUser, "PATH"); v.Value != "" {
+		t.Errorf("Get(PATH) = %q, want empty", v.Value)
+	}
+}
+
+// TestProfileStore_UserPathExpands checks that a $HOME entry keeps expanding
+// across writes, even when PATH is written back with the type it was read
+// with.
+func TestProfileStore_UserPathExpands(t *testing.T) {
+	s := newTestProfileStore(t, "", "")
+
+	if err := s.Set(ScopeUser, EnvVar{Key: "PATH", Value: "/opt/a", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	for _, entry := range []string{"$HOME/bin", "/opt/b"} {
+		v, _, err := s.Get(ScopeUser, "PATH")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Type != TypeExpandString {
+			t.Fatalf("Get(PATH).Type = %s, want %s", v.Type, TypeExpandString)
+		}
+		v.Value += ":" + entry
+		if err := s.Set(ScopeUser, v); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	if got := readFile(t, s.UserFile); !strings.Contains(got, "export PATH=\"$PATH\":\"/opt/a:$HOME/bin:/opt/b\"\n") {
+		t.Errorf("profile = %q, want PATH double-quoted", got)
+	}
+	v, _, _ := s.Get(ScopeUser, "PATH")
+	if v != (EnvVar{Key: "PATH", Value: "/opt/a:$HOME/bin:/opt/b", Type: TypeExpandString}) {
+		t.Errorf("Get(PATH) = %v, want the entries as REG_EXPAND_SZ", v)
+	}
+
+	// The shell expands $HOME when it reads the profile.
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	cmd := exec.Command(sh, "-c", `. "$1" && printf %s "$PATH"`, "sh", s.UserFile)
+	cmd.Env = []string{"HOME=/home/u", "PATH=/usr/bin"}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sh: %v", err)
+	}
+	if want := "/usr/bin:/opt/a:/home/u/bin:/opt/b"; string(out) != want {
+		t.Errorf("PATH in the shell = %q, want %q", out, want)
+	}
+}
+
+// TestProfileStore_HostileValueNotRun checks that sourcing the profile
+// expands only $NAME and ${NAME} references of a REG_EXPAND_SZ value and
+// never runs a command substitution in it.
+func TestProfileStore_HostileValueNotRun(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	s := newTestProfileStore(t, "", "")
+	marker := filepath.Join(t.TempDir(), "ran")
+	value := "$HOME/$(touch " + marker + ")/`touch " + marker + "`/$1/${HOME}/$"
+	if err := s.Set(ScopeUser, EnvVar{Key: "HOSTILE", Value: value, Type: TypeExpandString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	cmd := exec.Command(sh, "-c", `. "$1" && printf %s "$HOSTILE"`, "sh", s.UserFile)
+	cmd.Env = []string{"HOME=/home/u", "PATH=" + os.Getenv("PATH")}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sh: %v", err)
+	}
+	want := "/home/u/$(touch " + marker + ")/`touch " + marker + "`/$1//home/u/$"
+	if string(out) != want {
+		t.Errorf("HOSTILE in the shell = %q, want %q", out, want)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("sourcing the profile ran a command from the value")
+	}
+}
+
+func TestProfileStore_SystemPlainLines(t *testing.T) {
+	s := newTestProfileStore(t, "", "LANG=\"C.UTF-8\"\n")
+
+	if err := s.Set(ScopeSystem, EnvVar{Key: "JAVA_HOME", Value: "/opt/java 21", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	want := "LANG=\"C.UTF-8\"\n\n" + profileBlockBegin + "\n" + profileBlockHeader + "\n" +
+		"JAVA_HOME=/opt/java 21\n" + profileBlockEnd + "\n"
+	if got := readFile(t, s.SystemFile); got != want {
+		t.Errorf("environment =\n%s\nwant\n%s", got, want)
+	}
+	if v, _, _ := s.Get(ScopeSystem, "JAVA_HOME"); v != (EnvVar{Key: "JAVA_HOME", Value: "/opt/java 21", Type: TypeString}) {
+		t.Errorf("Get(JAVA_HOME) = %v, want the plain value", v)
+	}
+	if v, _, _ := s.Get(ScopeSystem, "LANG"); v.Value != "C.UTF-8" {
+		t.Errorf("Get(LANG) = %q, want quotes removed like pam_env", v.Value)
+	}
+
+	for _, v := range []EnvVar{
+		{Key: "GOBIN", Value: "$HOME/go/bin", Type: TypeString},
+		{Key: "X", Value: "/opt", Type: TypeExpandString},
+		{Key: "X", Value: "a\nb", Type: TypeString},
+		{Key: "X", Value: "'quoted'", Type: TypeString},
+		{Key: "X", Value: " padded", Type: TypeString},
+	} {
+		if err := s.Set(ScopeSystem, v); err == nil {
+			t.Errorf("Set(%q, %s) expected error", v.Value, v.Type)
+		}
+	}
+}
+
+func TestProfileStore_SystemReadsWholeFile(t *testing.T) {
+	s := newTestProfileStore(t, "", "PATH=\"/usr/local/bin:/usr/bin\"\nLANG=C\n")
+
+	v, ok, err := s.Get(ScopeSystem, "PATH")
+	if err != nil || !ok || v.Value != "/usr/local/bin:/usr/bin" {
+		t.Fatalf("Get(PATH) = %v, %v, %v, want /usr/local/bin:/usr/bin", v, ok, err)
+	}
+
+	if err := s.Set(ScopeSystem, EnvVar{Key: "PATH", Value: "/usr/local/bin:/usr/bin:/opt/bin", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	v, _, _ = s.Get(ScopeSystem, "PATH")
+	if v.Value != "/usr/local/bin:/usr/bin:/opt/bin" {
+		t.Errorf("Get(PATH) = %q, want block value", v.Value)
+	}
+	if got := readFile(t, s.SystemFile); !strings.HasPrefix(got, "PATH=\"/usr/local/bin:/usr/bin\"\nLANG=C\n") {
+		t.Errorf("environment = %q, want outside content untouched", got)
+	}
+
+	vars, _ := s.List(ScopeSystem)
+	if len(vars) != 2 {
+		t.Errorf("List() = %v, want LANG and PATH", vars)
+	}
+
+	if err := s.Delete(ScopeSystem, "LANG"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete(LANG) error = %v, want defined-outside error", err)
+	}
+	if err := s.Delete(ScopeSystem, "MISSING"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete(MISSING) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestProfileStore_CaseSensitive(t *testing.T) {
+	s := newTestProfileStore(t, "", "")
+	if err := s.Set(ScopeUser, EnvVar{Key: "foo", Value: "1", Type: TypeString}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := s.Get(ScopeUser, "FOO"); ok {
+		t.Error("Get(FOO) found foo, want case-sensitive keys")
+	}
+	if c := ConventionsOf(s); c.PathKey != "PATH" || c.ListSeparator != ":" || !c.CaseSensitive {
+		t.Errorf("ConventionsOf() = %+v, want POSIX conventions", c)
+	}
+}
+
+func TestProfileStore_NoUserFile(t *testing.T) {
+	s := NewProfileStore("", "")
+	if _, err := s.List(ScopeUser); err == nil {
+		t.Error("List() expected error without a profile file")
+	}
+}
+
+func TestQuoteShell(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		typ   ValueType
+	}{
+		{name: "plain", value: "/opt/java", typ: TypeString},
+		{name: "single quote", value: "it's", typ: TypeString},
+		{name: "literal dollar", value: "$NOT_EXPANDED", typ: TypeString},
+		{name: "reference", value: "$HOME/bin", typ: TypeExpandString},
+		{name: "reference with quotes", value: `${HOME}/"x"\y`, typ: TypeExpandString},
+		{name: "reference with command", value: "$HOME/$(id)/`id`/$1/${#}/$", typ: TypeExpandString},
+		{name: "empty", value: "", typ: TypeString},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quoted := quoteShell(tt.value, tt.typ)
+			value, typ := unquoteShell(quoted)
+			if value != tt.value || typ != tt.typ {
+				t.Errorf("unquoteShell(%s) = %q, %s, want %q, %s", quoted, value, typ, tt.value, tt.typ)
+			}
+		})
+	}
+}
+
+func TestUnquoteShell_Unquoted(t *testing.T) {
+	tests := []struct {
+		raw      string
+		want     string
+		wantType ValueType
+	}{
+		{raw: "C", want: "C", wantType: TypeString},
+		{raw: `a\ b`, want: "a b", wantType: TypeString},
+		{raw: "$HOME/x", want: "$HOME/x", wantType: TypeExpandString},
+		{raw: `"/usr/bin"`, want: "/usr/bin", wantType: TypeString},
+	}
+
+	for _, tt := range tests {
+		value, typ := unquoteShell(tt.raw)
+		if value != tt.want || typ != tt.wantType {
+			t.Errorf("unquoteShell(%s) = %q, %s, want %q, %s", tt.raw, value, typ, tt.want, tt.wantType)
+		}
+	}
+}
@@ -1,93 +1,39 @@
 package env
 
-import (
-	"os/exec"
-	"sort"
-	"strings"
-)
+import "strings"
 
-const (
-	userEnvRegPath   = "HKEY_CURRENT_USER\\Environment"
-	systemEnvRegPath = "HKEY_LOCAL_MACHINE\\SYSTEM\\CurrentControlSet\\Control\\Session Manager\\Environment"
-)
-
-// EnvVar represents an environment variable with key and value.
+// EnvVar represents an environment variable with key, value and value type.
 type EnvVar struct {
 	Key   string
 	Value string
+	Type  ValueType `json:",omitempty"`
 }
 
-// ListUser lists all user environment variables from registry.
+// ListUser lists all user environment variables from the current store.
 func ListUser() ([]EnvVar, error) {
-	return listFromRegistry(userEnvRegPath)
+	return current.List(ScopeUser)
 }
 
-// ListSystem lists all system environment variables from registry.
+// ListSystem lists all system environment variables from the current store.
 func ListSystem() ([]EnvVar, error) {
-	return listFromRegistry(systemEnvRegPath)
+	return current.List(ScopeSystem)
 }
 
 // GetUser gets a specific user environment variable value.
+// An unset variable yields an empty value and no error.
 func GetUser(key string) (string, error) {
-	return getFromRegistry(userEnvRegPath, key)
+	return getValue(ScopeUser, key)
 }
 
 // GetSystem gets a specific system environment variable value.
+// An unset variable yields an empty value and no error.
 func GetSystem(key string) (string, error) {
-	return getFromRegistry(systemEnvRegPath, key)
-}
-
-func listFromRegistry(regPath string) ([]EnvVar, error) {
-	cmd := exec.Command("reg", "query", regPath)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-
-	return parseRegOutput(string(output)), nil
-}
-
-func getFromRegistry(regPath, key string) (string, error) {
-	cmd := exec.Command("reg", "query", regPath, "/v", key)
-	output, err := cmd.Output()
-	if err != nil {
-		// Key not found is not an error, just return empty
-		return "", nil
-	}
-
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "HKEY_") {
-			continue
-		}
-		parts := parseRegLine(line)
-		if parts != nil && strings.EqualFold(parts.Key, key) {
-			return parts.Value, nil
-		}
-	}
-	return "", nil
+	return getValue(ScopeSystem, key)
 }
 
-func parseRegOutput(output string) []EnvVar {
-	lines := strings.Split(output, "\n")
-	var result []EnvVar
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "HKEY_") {
-			continue
-		}
-		if env := parseRegLine(line); env != nil {
-			result = append(result, *env)
-		}
-	}
-
-	sort.Slice(result, func(i, j int) bool {
-		return strings.ToLower(result[i].Key) < strings.ToLower(result[j].Key)
-	})
-
-	return result
+func getValue(scope Scope, key string) (string, error) {
+	v, _, err := current.Get(scope, key)
+	return v.Value, err
 }
 
 // SearchUser searches user env vars by keyword (case-insensitive, matches key or value).
@@ -119,30 +65,3 @@ func filterByKeyword(envVars []EnvVar, keyword string) []EnvVar {
 	}
 	return result
 }
-
-// parseRegLine parses a line like "    JAVA_HOME    REG_SZ    C:\Java\jdk"
-func parseRegLine(line string) *EnvVar {
-	// Registry output format: NAME    TYPE    VALUE
-	// Types: REG_SZ, REG_EXPAND_SZ, etc.
-	fields := strings.Fields(line)
-	if len(fields) < 3 {
-		return nil
-	}
-
-	key := fields[0]
-	regType := fields[1]
-
-	// Only handle string types
-	if regType != "REG_SZ" && regType != "REG_EXPAND_SZ" {
-		return nil
-	}
-
-	// Value may contain spaces, so rejoin everything after the type
-	valueStart := strings.Index(line, regType)
-	if valueStart == -1 {
-		return nil
-	}
-	value := strings.TrimSpace(line[valueStart+len(regType):])
-
-	return &EnvVar{Key: key, Value: value}
-}
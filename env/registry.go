@@ -0,0 +1,86 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+const systemEnvKey = "SYSTEM\\CurrentControlSet\\Control\\Session Manager\\Environment"
+
+// userEnvKey is the HKCU key of user variables. Tests point it at a
+// scratch key.
+var userEnvKey = "Environment"
+
+// errNoRegistry is returned by the registry store outside Windows.
+var errNoRegistry = errors.New("the registry is only available on Windows")
+
+// maxValueLength is the longest value, in UTF-16 code units, that Windows
+// accepts for an environment variable.
+const maxValueLength = 32767
+
+// ErrValueTooLong is returned when a value exceeds maxValueLength.
+var ErrValueTooLong = errors.New("value too long")
+
+// RegistryStore is the Store backed by the live Windows registry.
+// System scope writes require administrator privileges.
+type RegistryStore struct{}
+
+// List reads the values through the Unicode registry API rather than
+// reg.exe, whose output is limited to the console code page.
+func (RegistryStore) List(scope Scope) ([]EnvVar, error) {
+	vars, err := regList(scope)
+	if err != nil {
+		return nil, err
+	}
+	sortEnvVars(vars)
+	return vars, nil
+}
+
+// Get matches the name exactly, so that e.g. Path never resolves to
+// PATHEXT, and returns it as stored.
+func (RegistryStore) Get(scope Scope, key string) (EnvVar, bool, error) {
+	vars, err := regList(scope)
+	if err != nil {
+		return EnvVar{}, false, err
+	}
+	if i := indexOfVar(vars, key); i != -1 {
+		return vars[i], true, nil
+	}
+	return EnvVar{}, false, nil
+}
+
+// Set writes the variable with its value type through the registry API, so
+// that every type read by List can be written back. setx and
+// SetEnvironmentVariable are not used because they always store REG_SZ,
+// which stops %VAR% references from expanding.
+func (RegistryStore) Set(scope Scope, v EnvVar) error {
+	if err := checkValueLength(v); err != nil {
+		return err
+	}
+	if err := checkRegValue(v); err != nil {
+		return fmt.Errorf("cannot set %s: %w", v.Key, err)
+	}
+	if err := regSet(scope, v); err != nil {
+		return fmt.Errorf("cannot set %s: %w", v.Key, err)
+	}
+	broadcastEnvChange()
+	return nil
+}
+
+func (RegistryStore) Delete(scope Scope, key string) error {
+	if err := regDelete(scope, key); err != nil {
+		return err
+	}
+	broadcastEnvChange()
+	return nil
+}
+
+// checkValueLength rejects values that Windows would not load into the
+// environment of new processes.
+func checkValueLength(v EnvVar) error {
+	if n := len(utf16.Encode([]rune(v.Value))); n > maxValueLength {
+		return fmt.Errorf("cannot set %s: %w: %d characters, the limit is %d", v.Key, ErrValueTooLong, n, maxValueLength)
+	}
+	return nil
+}
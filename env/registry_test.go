@@ -0,0 +1,57 @@
+package env
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckValueLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "empty", value: ""},
+		{name: "old setx limit", value: strings.Repeat("x", 1025)},
+		{name: "at limit", value: strings.Repeat("x", maxValueLength)},
+		{name: "over limit", value: strings.Repeat("x", maxValueLength+1), wantErr: true},
+		{name: "CJK at limit", value: strings.Repeat("中", maxValueLength)},
+		{name: "surrogate pairs over limit", value: strings.Repeat("\U0001F600", maxValueLength/2+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkValueLength(EnvVar{Key: "Path", Value: tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkValueLength() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrValueTooLong) {
+				t.Errorf("checkValueLength() error = %v, want ErrValueTooLong", err)
+			}
+		})
+	}
+}
+
+func TestCheckRegValue(t *testing.T) {
+	tests := []struct {
+		v       EnvVar
+		wantErr bool
+	}{
+		{v: EnvVar{Value: `%JAVA_HOME%\bin`, Type: TypeExpandString}},
+		{v: EnvVar{Value: "a;b" + multiStringSep + "c", Type: TypeMultiString}},
+		{v: EnvVar{Value: "4294967295", Type: TypeDWord}},
+		{v: EnvVar{Value: "4294967296", Type: TypeDWord}, wantErr: true},
+		{v: EnvVar{Value: "0x10", Type: TypeDWord}, wantErr: true},
+		{v: EnvVar{Value: "18446744073709551615", Type: TypeQWord}},
+		{v: EnvVar{Value: "-1", Type: TypeQWord}, wantErr: true},
+		{v: EnvVar{Value: "01AB", Type: TypeBinary}},
+		{v: EnvVar{Value: "xyz", Type: TypeBinary}, wantErr: true},
+		{v: EnvVar{Value: "", Type: TypeNone}, wantErr: true},
+		{v: EnvVar{Value: "x", Type: ""}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := checkRegValue(tt.v); (err != nil) != tt.wantErr {
+			t.Errorf("checkRegValue(%q %s) error = %v, wantErr %v", tt.v.Value, tt.v.Type, err, tt.wantErr)
+		}
+	}
+}
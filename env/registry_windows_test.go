@@ -0,0 +1,134 @@
+//go:build windows
+
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// useScratchUserKey points the user scope of RegistryStore at a new HKCU
+// subkey that is deleted when the test ends.
+func useScratchUserKey(t *testing.T) RegistryStore {
+	t.Helper()
+	name := fmt.Sprintf(`Software\menv-test-%d-%s`, os.Getpid(), strings.ReplaceAll(t.Name(), "/", "-"))
+	k, _, err := registry.CreateKey(registry.CURRENT_USER, name, registry.ALL_ACCESS)
+	if err != nil {
+		t.Fatalf("CreateKey(%s) error = %v", name, err)
+	}
+	k.Close()
+	prev := userEnvKey
+	userEnvKey = name
+	t.Cleanup(func() {
+		userEnvKey = prev
+		if err := registry.DeleteKey(registry.CURRENT_USER, name); err != nil {
+			t.Errorf("DeleteKey(%s) error = %v", name, err)
+		}
+	})
+	return RegistryStore{}
+}
+
+func TestRegistryStore_RoundTrip(t *testing.T) {
+	s := useScratchUserKey(t)
+
+	tests := []struct {
+		name string
+		v    EnvVar
+	}{
+		{name: "quotes", v: EnvVar{Key: "QUOTES", Value: `say "hi" and 'bye'`, Type: TypeString}},
+		{name: "command substitution", v: EnvVar{Key: "SUBST", Value: "$(rm -rf ~) $HOME ${HOME}", Type: TypeString}},
+		{name: "backticks", v: EnvVar{Key: "BACKTICKS", Value: "`whoami`", Type: TypeString}},
+		{name: "percent in REG_SZ", v: EnvVar{Key: "RATE", Value: "100% of %JAVA_HOME%", Type: TypeString}},
+		{name: "reference in REG_EXPAND_SZ", v: EnvVar{Key: "JAVA_BIN", Value: `%JAVA_HOME%\bin`, Type: TypeExpandString}},
+		{name: "newlines", v: EnvVar{Key: "LINES", Value: "one\r\ntwo\nthree", Type: TypeString}},
+		{name: "CJK", v: EnvVar{Key: "路径", Value: `C:\工具\中文`, Type: TypeString}},
+		{name: "emoji", v: EnvVar{Key: "EMOJI", Value: "\U0001F600 \U0001F680", Type: TypeString}},
+		{name: "name with spaces", v: EnvVar{Key: "My Var", Value: "x", Type: TypeString}},
+		{name: "at the length limit", v: EnvVar{Key: "LONG", Value: strings.Repeat("x", maxValueLength), Type: TypeString}},
+		{name: "CJK at the length limit", v: EnvVar{Key: "LONG_CJK", Value: strings.Repeat("中", maxValueLength), Type: TypeString}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Set(ScopeUser, tt.v); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			got, ok, err := s.Get(ScopeUser, tt.v.Key)
+			if err != nil || !ok {
+				t.Fatalf("Get(%s) = %v, %v", tt.v.Key, ok, err)
+			}
+			if got != tt.v {
+				t.Errorf("Get(%s) = %q %s, want %q %s", tt.v.Key, truncate(got.Value), got.Type, truncate(tt.v.Value), tt.v.Type)
+			}
+		})
+	}
+}
+
+func TestRegistryStore_ValueTooLong(t *testing.T) {
+	s := useScratchUserKey(t)
+
+	for _, value := range []string{
+		strings.Repeat("x", maxValueLength+1),
+		strings.Repeat("\U0001F600", maxValueLength/2+1),
+	} {
+		err := s.Set(ScopeUser, EnvVar{Key: "LONG", Value: value, Type: TypeString})
+		if !errors.Is(err, ErrValueTooLong) {
+			t.Errorf("Set() of %d runes error = %v, want ErrValueTooLong", len([]rune(value)), err)
+		}
+	}
+	if _, ok, _ := s.Get(ScopeUser, "LONG"); ok {
+		t.Error("Get(LONG) found a value that was too long to set")
+	}
+}
+
+// TestRegistryStore_ExactNames checks that Path never resolves to PATHEXT
+// and that names with spaces are matched whole.
+func TestRegistryStore_ExactNames(t *testing.T) {
+	s := useScratchUserKey(t)
+
+	for _, v := range []EnvVar{
+		{Key: "PATHEXT", Value: ".COM;.EXE", Type: TypeString},
+		{Key: "My Var", Value: "spaced", Type: TypeString},
+	} {
+		if err := s.Set(ScopeUser, v); err != nil {
+			t.Fatalf("Set(%s) error = %v", v.Key, err)
+		}
+	}
+
+	for _, key := range []string{"Path", "My", "Var", "MyVar"} {
+		if v, ok, err := s.Get(ScopeUser, key); err != nil || ok {
+			t.Errorf("Get(%q) = %v, %v, %v, want not found", key, v, ok, err)
+		}
+	}
+	if v, ok, _ := s.Get(ScopeUser, "my var"); !ok || v.Value != "spaced" {
+		t.Errorf("Get(\"my var\") = %v, %v, want the case-insensitive match of My Var", v, ok)
+	}
+
+	if err := s.Set(ScopeUser, EnvVar{Key: "Path", Value: `C:\bin`, Type: TypeExpandString}); err != nil {
+		t.Fatalf("Set(Path) error = %v", err)
+	}
+	if v, _, _ := s.Get(ScopeUser, "PATHEXT"); v.Value != ".COM;.EXE" {
+		t.Errorf("PATHEXT = %q after setting Path, want it unchanged", v.Value)
+	}
+	if err := s.Delete(ScopeUser, "Path"); err != nil {
+		t.Fatalf("Delete(Path) error = %v", err)
+	}
+	if _, ok, _ := s.Get(ScopeUser, "PATHEXT"); !ok {
+		t.Error("Delete(Path) also removed PATHEXT")
+	}
+	if err := s.Delete(ScopeUser, "Path"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete(Path) again error = %v, want ErrNotFound", err)
+	}
+}
+
+// truncate shortens long values in failure messages.
+func truncate(s string) string {
+	if r := []rune(s); len(r) > 40 {
+		return string(r[:40]) + fmt.Sprintf("... (%d runes)", len(r))
+	}
+	return s
+}
@@ -0,0 +1,132 @@
+package env
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	TypeMultiString ValueType = "REG_MULTI_SZ"
+	TypeDWord       ValueType = "REG_DWORD"
+	TypeQWord       ValueType = "REG_QWORD"
+	TypeBinary      ValueType = "REG_BINARY"
+	TypeNone        ValueType = "REG_NONE"
+)
+
+// Registry value type numbers, as returned by RegQueryValueEx.
+const (
+	regNone     = 0
+	regSZ       = 1
+	regExpandSZ = 2
+	regBinary   = 3
+	regDWord    = 4
+	regMultiSZ  = 7
+	regQWord    = 11
+)
+
+// multiStringSep joins the items of a REG_MULTI_SZ value. Items cannot
+// contain NUL, so unlike ';' it keeps them apart exactly.
+const multiStringSep = "\x00"
+
+// regTypeNames maps registry value type numbers to their names.
+var regTypeNames = map[uint32]ValueType{
+	regNone:     TypeNone,
+	regSZ:       TypeString,
+	regExpandSZ: TypeExpandString,
+	regBinary:   TypeBinary,
+	regDWord:    TypeDWord,
+	regMultiSZ:  TypeMultiString,
+	regQWord:    TypeQWord,
+}
+
+// decodeRegValue converts the raw data of a registry value to a variable.
+// Strings are stored as UTF-16, so names and values in any language come
+// back unchanged. Numbers become decimal, REG_MULTI_SZ items are joined with
+// multiStringSep and other data is shown as hex.
+func decodeRegValue(name string, typ uint32, data []byte) EnvVar {
+	v := EnvVar{Key: name, Type: regTypeNames[typ]}
+	if v.Type == "" {
+		v.Type = ValueType("REG_" + strconv.FormatUint(uint64(typ), 10))
+	}
+	switch typ {
+	case regSZ, regExpandSZ:
+		v.Value = strings.TrimRight(decodeUTF16(data), "\x00")
+	case regMultiSZ:
+		items := strings.Split(strings.TrimRight(decodeUTF16(data), "\x00"), "\x00")
+		v.Value = strings.Join(items, multiStringSep)
+	case regDWord:
+		if len(data) >= 4 {
+			v.Value = strconv.FormatUint(uint64(binary.LittleEndian.Uint32(data)), 10)
+			break
+		}
+		v.Value = strings.ToUpper(hex.EncodeToString(data))
+	case regQWord:
+		if len(data) >= 8 {
+			v.Value = strconv.FormatUint(binary.LittleEndian.Uint64(data), 10)
+			break
+		}
+		v.Value = strings.ToUpper(hex.EncodeToString(data))
+	default:
+		v.Value = strings.ToUpper(hex.EncodeToString(data))
+	}
+	return v
+}
+
+// decodeUTF16 decodes little-endian UTF-16 data, ignoring a trailing odd byte.
+func decodeUTF16(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// checkRegValue reports whether v.Value can be written as v.Type.
+func checkRegValue(v EnvVar) error {
+	switch v.Type {
+	case TypeString, TypeExpandString, TypeMultiString:
+		return nil
+	case TypeDWord, TypeQWord:
+		_, err := regNumber(v)
+		return err
+	case TypeBinary:
+		_, err := regBytes(v)
+		return err
+	default:
+		return fmt.Errorf("%s values cannot be written", v.Type)
+	}
+}
+
+// regNumber parses the decimal value of a REG_DWORD or REG_QWORD.
+func regNumber(v EnvVar) (uint64, error) {
+	bits := 64
+	if v.Type == TypeDWord {
+		bits = 32
+	}
+	n, err := strconv.ParseUint(v.Value, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q, want a number from 0 to %d", v.Type, v.Value, uint64(1)<<bits-1)
+	}
+	return n, nil
+}
+
+// regBytes parses the hex value of a REG_BINARY.
+func regBytes(v EnvVar) ([]byte, error) {
+	b, err := hex.DecodeString(v.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value %q, want hex digits", v.Type, v.Value)
+	}
+	return b, nil
+}
+
+// multiStringItems splits a REG_MULTI_SZ value into its items.
+func multiStringItems(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, multiStringSep)
+}
@@ -0,0 +1,149 @@
+package env
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// regSZData returns s as the registry stores REG_SZ data: UTF-16LE with a
+// terminating NUL.
+func regSZData(s string) []byte {
+	units := utf16.Encode([]rune(s + "\x00"))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+func TestDecodeRegValue(t *testing.T) {
+	dword := binary.LittleEndian.AppendUint32(nil, 0xFFFFFFFF)
+	qword := binary.LittleEndian.AppendUint64(nil, 1<<40)
+
+	tests := []struct {
+		name     string
+		typ      uint32
+		data     []byte
+		wantVal  string
+		wantType ValueType
+	}{
+		{
+			name:     "REG_SZ",
+			typ:      regSZ,
+			data:     regSZData(`C:\Program Files\Java\jdk`),
+			wantVal:  `C:\Program Files\Java\jdk`,
+			wantType: TypeString,
+		},
+		{
+			name:     "REG_EXPAND_SZ is not expanded",
+			typ:      regExpandSZ,
+			data:     regSZData(`%USERPROFILE%\bin`),
+			wantVal:  `%USERPROFILE%\bin`,
+			wantType: TypeExpandString,
+		},
+		{
+			name:     "Chinese user profile path",
+			typ:      regExpandSZ,
+			data:     regSZData(`C:\Users\张三\工具;%USERPROFILE%\bin`),
+			wantVal:  `C:\Users\张三\工具;%USERPROFILE%\bin`,
+			wantType: TypeExpandString,
+		},
+		{
+			name:     "Japanese path outside the OEM code page",
+			typ:      regSZ,
+			data:     regSZData(`C:\Users\山田\ドキュメント`),
+			wantVal:  `C:\Users\山田\ドキュメント`,
+			wantType: TypeString,
+		},
+		{
+			name:     "surrogate pairs",
+			typ:      regSZ,
+			data:     regSZData("emoji \U0001F600"),
+			wantVal:  "emoji \U0001F600",
+			wantType: TypeString,
+		},
+		{
+			name:     "empty",
+			typ:      regSZ,
+			data:     nil,
+			wantVal:  "",
+			wantType: TypeString,
+		},
+		{
+			name:     "missing terminator",
+			typ:      regSZ,
+			data:     regSZData("abc")[:6],
+			wantVal:  "abc",
+			wantType: TypeString,
+		},
+		{
+			name:     "REG_DWORD",
+			typ:      regDWord,
+			data:     dword,
+			wantVal:  "4294967295",
+			wantType: TypeDWord,
+		},
+		{
+			name:     "REG_QWORD",
+			typ:      regQWord,
+			data:     qword,
+			wantVal:  "1099511627776",
+			wantType: TypeQWord,
+		},
+		{
+			name:     "REG_MULTI_SZ items containing ';'",
+			typ:      regMultiSZ,
+			data:     append(append(regSZData(`C:\a;C:\b`), regSZData("c")...), 0, 0),
+			wantVal:  `C:\a;C:\b` + multiStringSep + "c",
+			wantType: TypeMultiString,
+		},
+		{
+			name:     "empty REG_MULTI_SZ",
+			typ:      regMultiSZ,
+			data:     []byte{0, 0},
+			wantVal:  "",
+			wantType: TypeMultiString,
+		},
+		{
+			name:     "REG_BINARY",
+			typ:      regBinary,
+			data:     []byte{0x01, 0xab},
+			wantVal:  "01AB",
+			wantType: TypeBinary,
+		},
+		{
+			name:     "unknown type",
+			typ:      42,
+			data:     []byte{0xff},
+			wantVal:  "FF",
+			wantType: "REG_42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeRegValue("K", tt.typ, tt.data)
+			if got.Key != "K" || got.Value != tt.wantVal || got.Type != tt.wantType {
+				t.Errorf("decodeRegValue() = %+v, want {K %q %s}", got, tt.wantVal, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestMultiStringItems(t *testing.T) {
+	items := []string{`C:\a;C:\b`, "c"}
+	var data []byte
+	for _, item := range items {
+		data = append(data, regSZData(item)...)
+	}
+	v := decodeRegValue("K", regMultiSZ, append(data, 0, 0))
+
+	got := multiStringItems(v.Value)
+	if len(got) != len(items) || got[0] != items[0] || got[1] != items[1] {
+		t.Errorf("multiStringItems() = %q, want %q", got, items)
+	}
+	if got := multiStringItems(""); got != nil {
+		t.Errorf("multiStringItems(\"\") = %q, want none", got)
+	}
+}
@@ -0,0 +1,523 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Scope selects which set of environment variables an operation targets.
+type Scope int
+
+const (
+	ScopeUser Scope = iota
+	ScopeSystem
+)
+
+// ScopeOf returns ScopeSystem if isSystem is true, ScopeUser otherwise.
+func ScopeOf(isSystem bool) Scope {
+	if isSystem {
+		return ScopeSystem
+	}
+	return ScopeUser
+}
+
+func (s Scope) String() string {
+	if s == ScopeSystem {
+		return "system"
+	}
+	return "user"
+}
+
+// ValueType is the registry value type of an environment variable.
+type ValueType string
+
+const (
+	TypeString       ValueType = "REG_SZ"
+	TypeExpandString ValueType = "REG_EXPAND_SZ"
+)
+
+var (
+	// varReference matches a %NAME% reference inside a value.
+	varReference = regexp.MustCompile(`%[^%]+%`)
+	// shellReference matches a $NAME or ${NAME} reference inside a value.
+	shellReference = regexp.MustCompile(`\$(\{[A-Za-z_][A-Za-z0-9_]*\}|[A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// Conventions describes how the variables of a store are named and composed.
+type Conventions struct {
+	// PathKey is the name of the PATH variable.
+	PathKey string
+	// ListSeparator separates the entries of PATH-like variables.
+	ListSeparator string
+	// CaseSensitive reports whether keys and paths differ by case.
+	CaseSensitive bool
+	// Reference matches a reference to another variable inside a value.
+	Reference *regexp.Regexp
+}
+
+var (
+	windowsConventions = Conventions{PathKey: "Path", ListSeparator: ";", Reference: varReference}
+	posixConventions   = Conventions{PathKey: "PATH", ListSeparator: ":", CaseSensitive: true, Reference: shellReference}
+)
+
+// ConventionsOf returns the conventions of s. Stores that do not describe
+// their own conventions follow Windows ones.
+func ConventionsOf(s Store) Conventions {
+	if c, ok := s.(interface{ Conventions() Conventions }); ok {
+		return c.Conventions()
+	}
+	return windowsConventions
+}
+
+// inferType returns REG_EXPAND_SZ for values referencing other variables
+// and REG_SZ otherwise.
+func inferType(value string) ValueType {
+	if ConventionsOf(current).Reference.MatchString(value) {
+		return TypeExpandString
+	}
+	return TypeString
+}
+
+// ErrNotFound is returned when deleting a variable that does not exist.
+var ErrNotFound = errors.New("environment variable not found")
+
+// Store is a backend holding user and system environment variables.
+// Keys are matched case-insensitively unless the store's Conventions say otherwise.
+type Store interface {
+	// List returns all variables of the scope, sorted by key.
+	List(scope Scope) ([]EnvVar, error)
+	// Get returns the variable named key; ok is false if it is not set.
+	Get(scope Scope, key string) (v EnvVar, ok bool, err error)
+	// Set creates or replaces a variable.
+	Set(scope Scope, v EnvVar) error
+	// Delete removes a variable.
+	Delete(scope Scope, key string) error
+}
+
+var current = defaultStore()
+
+// CurrentStore returns the store used by the package-level functions.
+func CurrentStore() Store {
+	return current
+}
+
+// UseStore replaces the store used by the package-level functions.
+func UseStore(s Store) {
+	current = s
+}
+
+// StoreWrite is a write made through a WatchedStore.
+type StoreWrite struct {
+	Scope Scope
+	Key   string
+	// Old is the variable the write replaced, if Existed.
+	Old     EnvVar
+	Existed bool
+	// New is the variable written, or nil for a delete.
+	New *EnvVar
+	// Err is the error the write failed with.
+	Err error
+}
+
+// WatchedStore is a Store reporting every write made through it, like the
+// journal and the audit log need.
+type WatchedStore struct {
+	Store
+	onWrite func(w StoreWrite)
+}
+
+// Watch returns a Store writing to base and calling onWrite after each
+// write, failed or not. Writes whose old value cannot be read are not made.
+func Watch(base Store, onWrite func(w StoreWrite)) *WatchedStore {
+	return &WatchedStore{Store: base, onWrite: onWrite}
+}
+
+// Conventions returns the conventions of the wrapped store.
+func (s *WatchedStore) Conventions() Conventions {
+	return ConventionsOf(s.Store)
+}
+
+func (s *WatchedStore) Set(scope Scope, v EnvVar) error {
+	old, ok, err := s.Store.Get(scope, v.Key)
+	if err != nil {
+		return err
+	}
+	err = s.Store.Set(scope, v)
+	s.onWrite(StoreWrite{Scope: scope, Key: v.Key, Old: old, Existed: ok, New: &v, Err: err})
+	return err
+}
+
+func (s *WatchedStore) Delete(scope Scope, key string) error {
+	old, ok, err := s.Store.Get(scope, key)
+	if err != nil {
+		return err
+	}
+	err = s.Store.Delete(scope, key)
+	s.onWrite(StoreWrite{Scope: scope, Key: key, Old: old, Existed: ok, Err: err})
+	return err
+}
+
+// Locker takes the lock serializing writes to scope with other processes
+// and returns the function releasing it.
+type Locker func(scope Scope) (release func(), err error)
+
+var (
+	locker Locker
+	// held counts the nested Lock calls holding each scope's lock.
+	held = make(map[Scope]int)
+)
+
+// UseLocker makes writes hold the lock returned by l while they compare and
+// write. A nil l turns locking off.
+func UseLocker(l Locker) {
+	locker = l
+}
+
+// Lock takes the locks of scopes, user before system, and returns the
+// function releasing them. A lock already held by an outer Lock call is not
+// taken again.
+func Lock(scopes ...Scope) (release func(), err error) {
+	var releases []func()
+	release = func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	for _, scope := range []Scope{ScopeUser, ScopeSystem} {
+		if locker == nil || !slices.Contains(scopes, scope) {
+			continue
+		}
+		if held[scope] == 0 {
+			r, err := locker(scope)
+			if err != nil {
+				release()
+				return nil, err
+			}
+			releases = append(releases, r)
+		}
+		held[scope]++
+		releases = append(releases, func() { held[scope]-- })
+	}
+	return release, nil
+}
+
+// OpenStore opens a store from a spec: "registry", "profile",
+// "environment.d", "memory", or "file:<path>" for a JSON file.
+// An empty spec opens the platform default.
+func OpenStore(spec string) (Store, error) {
+	switch {
+	case spec == "":
+		return defaultStore(), nil
+	case spec == "registry":
+		return RegistryStore{}, nil
+	case spec == "profile":
+		return defaultProfileStore(), nil
+	case spec == "environment.d":
+		return defaultEnvironmentDStore(), nil
+	case spec == "memory":
+		return NewMemoryStore(), nil
+	case strings.HasPrefix(spec, "file:"):
+		filename := strings.TrimPrefix(spec, "file:")
+		if filename == "" {
+			return nil, errors.New("missing file name in store spec: " + spec)
+		}
+		return NewFileStore(filename), nil
+	default:
+		return nil, fmt.Errorf("unknown store: %s", spec)
+	}
+}
+
+// Change is the effect of setting or deleting a variable.
+type Change int
+
+const (
+	Unchanged Change = iota
+	Created
+	Updated
+	Deleted
+)
+
+func (c Change) String() string {
+	switch c {
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	case Deleted:
+		return "deleted"
+	default:
+		return "unchanged"
+	}
+}
+
+// ChangeSummary counts the changes of a batch of writes.
+type ChangeSummary struct {
+	Created, Updated, Deleted, Unchanged int
+}
+
+// Add counts c.
+func (s *ChangeSummary) Add(c Change) {
+	switch c {
+	case Created:
+		s.Created++
+	case Updated:
+		s.Updated++
+	case Deleted:
+		s.Deleted++
+	default:
+		s.Unchanged++
+	}
+}
+
+// Total returns the number of writes counted.
+func (s ChangeSummary) Total() int {
+	return s.Created + s.Updated + s.Deleted + s.Unchanged
+}
+
+// Changed returns the number of writes that created, updated or deleted a
+// variable.
+func (s ChangeSummary) Changed() int {
+	return s.Created + s.Updated + s.Deleted
+}
+
+// String lists the counts, leaving out deletions when there are none.
+func (s ChangeSummary) String() string {
+	if s.Deleted == 0 {
+		return fmt.Sprintf("%d created, %d updated, %d unchanged", s.Created, s.Updated, s.Unchanged)
+	}
+	return fmt.Sprintf("%d created, %d updated, %d deleted, %d unchanged", s.Created, s.Updated, s.Deleted, s.Unchanged)
+}
+
+// setVar writes v to the current store unless the scope already holds the
+// same value and type. An empty v.Type keeps the type of the existing
+// variable, or is inferred from the value for new ones.
+func setVar(scope Scope, v EnvVar) (Change, error) {
+	var c Changeset
+	c.Set(scope, v)
+	results, err := c.Apply()
+	if err != nil {
+		return Unchanged, err
+	}
+	return results[0].Change, nil
+}
+
+// Changeset is a batch of writes to the current store that is applied as a
+// unit: if one write fails, the writes already made are rolled back.
+type Changeset struct {
+	ops []changeOp
+}
+
+type changeOp struct {
+	scope  Scope
+	v      EnvVar
+	delete bool
+	// expect is the value the variable must hold for the write to be made.
+	expect *string
+}
+
+// ErrConflict is returned when a variable written with SetIf has changed
+// since it was read.
+var ErrConflict = errors.New("changed since it was read")
+
+// ChangeResult is one write of a Changeset, with the state it replaces.
+type ChangeResult struct {
+	Scope  Scope
+	Change Change
+	// Old is the variable before the write; Existed reports whether there
+	// was one.
+	Old     EnvVar
+	Existed bool
+	// New is the variable after the write, or the deleted key.
+	New EnvVar
+}
+
+// Set adds a write of v to the changeset. As for setVar, an empty Type
+// keeps the type of the existing variable.
+func (c *Changeset) Set(scope Scope, v EnvVar) {
+	c.ops = append(c.ops, changeOp{scope: scope, v: v})
+}
+
+// SetIf adds a write of v that is only made if the variable still holds
+// old, an empty old meaning it is not set. If it does not, Apply fails with
+// ErrConflict before writing anything.
+func (c *Changeset) SetIf(scope Scope, v EnvVar, old string) {
+	c.ops = append(c.ops, changeOp{scope: scope, v: v, expect: &old})
+}
+
+// Delete adds the deletion of key to the changeset. Deleting a variable
+// that does not exist is not an error.
+func (c *Changeset) Delete(scope Scope, key string) {
+	c.ops = append(c.ops, changeOp{scope: scope, v: EnvVar{Key: key}, delete: true})
+}
+
+// DeleteIf adds the deletion of key that is only made if the variable
+// still holds old. If it does not, Apply fails with ErrConflict before
+// writing anything.
+func (c *Changeset) DeleteIf(scope Scope, key, old string) {
+	c.ops = append(c.ops, changeOp{scope: scope, v: EnvVar{Key: key}, delete: true, expect: &old})
+}
+
+// PlannedChanges returns a changeset making exactly the changes of a plan:
+// writes that change nothing are left out, and the others fail with
+// ErrConflict if their variable no longer holds the planned old value.
+func PlannedChanges(plan []ChangeResult) *Changeset {
+	var c Changeset
+	for _, r := range plan {
+		old := ""
+		if r.Existed {
+			old = r.Old.Value
+		}
+		switch r.Change {
+		case Created, Updated:
+			c.SetIf(r.Scope, r.New, old)
+		case Deleted:
+			c.DeleteIf(r.Scope, r.New.Key, old)
+		}
+	}
+	return &c
+}
+
+// Len returns the number of writes in the changeset.
+func (c *Changeset) Len() int {
+	return len(c.ops)
+}
+
+// Plan returns what Apply would do, without writing anything. Writes to
+// the same key see the result of the earlier ones.
+func (c *Changeset) Plan() ([]ChangeResult, error) {
+	type pending struct {
+		v  EnvVar
+		ok bool
+	}
+	conv := ConventionsOf(current)
+	state := make(map[string]pending)
+	id := func(scope Scope, key string) string {
+		if !conv.CaseSensitive {
+			key = strings.ToLower(key)
+		}
+		return scope.String() + "\x00" + key
+	}
+
+	results := make([]ChangeResult, 0, len(c.ops))
+	for _, op := range c.ops {
+		p, seen := state[id(op.scope, op.v.Key)]
+		if !seen {
+			old, ok, err := current.Get(op.scope, op.v.Key)
+			if err != nil {
+				return nil, err
+			}
+			p = pending{old, ok}
+		}
+		if op.expect != nil && p.v.Value != *op.expect {
+			return nil, fmt.Errorf("%s [%s] %w", op.v.Key, op.scope, ErrConflict)
+		}
+
+		r := ChangeResult{Scope: op.scope, Old: p.v, Existed: p.ok, New: op.v}
+		switch {
+		case op.delete && p.ok:
+			r.Change = Deleted
+			p = pending{}
+		case op.delete:
+			r.Change = Unchanged
+		default:
+			if r.New.Type == "" {
+				r.New.Type = inferType(r.New.Value)
+				if p.ok {
+					r.New.Type = p.v.Type
+				}
+			}
+			switch {
+			case !p.ok:
+				r.Change = Created
+			case p.v.Value != r.New.Value || p.v.Type != r.New.Type:
+				r.Change = Updated
+			}
+			p = pending{r.New, true}
+		}
+		state[id(op.scope, op.v.Key)] = p
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// scopes returns the scopes the changeset writes to.
+func (c *Changeset) scopes() []Scope {
+	var scopes []Scope
+	for _, op := range c.ops {
+		if !slices.Contains(scopes, op.scope) {
+			scopes = append(scopes, op.scope)
+		}
+	}
+	return scopes
+}
+
+// Apply writes the changeset to the current store, skipping writes that
+// change nothing. The scopes written to are locked from the comparison with
+// the current values until the last write. If a write fails, the earlier
+// ones are undone in reverse order and the error is returned along with any
+// failure to undo them.
+func (c *Changeset) Apply() ([]ChangeResult, error) {
+	release, err := Lock(c.scopes()...)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results, err := c.Plan()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, r := range results {
+		if err := r.apply(); err != nil {
+			err = fmt.Errorf("%s %s: %w", r.Scope, r.New.Key, err)
+			if rbErr := rollback(results[:i]); rbErr != nil {
+				return nil, errors.Join(err, fmt.Errorf("rollback failed: %w", rbErr))
+			}
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (r ChangeResult) apply() error {
+	switch r.Change {
+	case Created, Updated:
+		return current.Set(r.Scope, r.New)
+	case Deleted:
+		return current.Delete(r.Scope, r.New.Key)
+	default:
+		return nil
+	}
+}
+
+// rollback undoes results in reverse order, restoring their old state.
+func rollback(results []ChangeResult) error {
+	var errs []error
+	for i := len(results) - 1; i >= 0; i-- {
+		r := results[i]
+		var err error
+		switch {
+		case r.Change == Unchanged:
+			continue
+		case r.Existed:
+			err = current.Set(r.Scope, r.Old)
+		default:
+			err = current.Delete(r.Scope, r.New.Key)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", r.Scope, r.New.Key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sortEnvVars(envVars []EnvVar) {
+	sort.Slice(envVars, func(i, j int) bool {
+		return strings.ToLower(envVars[i].Key) < strings.ToLower(envVars[j].Key)
+	})
+}
@@ -0,0 +1,627 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestScopeOf(t *testing.T) {
+	if got := ScopeOf(true); got != ScopeSystem {
+		t.Errorf("ScopeOf(true) = %v, want %v", got, ScopeSystem)
+	}
+	if got := ScopeOf(false); got != ScopeUser {
+		t.Errorf("ScopeOf(false) = %v, want %v", got, ScopeUser)
+	}
+	if ScopeUser.String() != "user" || ScopeSystem.String() != "system" {
+		t.Errorf("Scope.String() = %q/%q, want user/system", ScopeUser, ScopeSystem)
+	}
+}
+
+func TestOpenStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		wantType string
+		wantErr  bool
+	}{
+		{name: "default", spec: "", wantType: fmt.Sprintf("%T", defaultStore())},
+		{name: "profile", spec: "profile", wantType: "*env.ProfileStore"},
+		{name: "environment.d", spec: "environment.d", wantType: "*env.EnvironmentDStore"},
+		{name: "registry", spec: "registry", wantType: "env.RegistryStore"},
+		{name: "memory", spec: "memory", wantType: "*env.MemoryStore"},
+		{name: "file", spec: "file:env.json", wantType: "*env.FileStore"},
+		{name: "file without name", spec: "file:", wantErr: true},
+		{name: "unknown", spec: "redis", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OpenStore(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenStore(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if gotType := fmt.Sprintf("%T", got); gotType != tt.wantType {
+				t.Errorf("OpenStore(%q) = %s, want %s", tt.spec, gotType, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"file": func(t *testing.T) Store {
+			return NewFileStore(filepath.Join(t.TempDir(), "store.json"))
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, newStore(t))
+		})
+	}
+}
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+
+	if err := s.Set(ScopeUser, EnvVar{Key: "Zeta", Value: "z", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := s.Set(ScopeUser, EnvVar{Key: "Path", Value: "%HOME%\\bin", Type: TypeExpandString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := s.Set(ScopeSystem, EnvVar{Key: "OS", Value: "Windows_NT", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	got, err := s.List(ScopeUser)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []EnvVar{
+		{Key: "Path", Value: "%HOME%\\bin", Type: TypeExpandString},
+		{Key: "Zeta", Value: "z", Type: TypeString},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("List() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("List()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// Keys are case-insensitive and keep their original casing.
+	if err := s.Set(ScopeUser, EnvVar{Key: "PATH", Value: "C:\\bin", Type: TypeString}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	v, ok, err := s.Get(ScopeUser, "path")
+	if err != nil || !ok {
+		t.Fatalf("Get() = %v, %v, %v, want found", v, ok, err)
+	}
+	if v.Key != "Path" || v.Value != "C:\\bin" || v.Type != TypeString {
+		t.Errorf("Get() = %v, want {Path C:\\bin REG_SZ}", v)
+	}
+
+	// Scopes are independent.
+	if _, ok, _ := s.Get(ScopeSystem, "Zeta"); ok {
+		t.Error("Get(ScopeSystem, Zeta) found a user variable")
+	}
+
+	if err := s.Delete(ScopeUser, "zeta"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok, _ := s.Get(ScopeUser, "Zeta"); ok {
+		t.Error("Get() after Delete() found the variable")
+	}
+	if err := s.Delete(ScopeUser, "Zeta"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() of missing key error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileStore_Persists(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "store.json")
+	if err := NewFileStore(filename).Set(ScopeSystem, EnvVar{Key: "FOO", Value: "bar"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	v, ok, err := NewFileStore(filename).Get(ScopeSystem, "FOO")
+	if err != nil || !ok || v.Value != "bar" {
+		t.Errorf("Get() = %v, %v, %v, want bar", v, ok, err)
+	}
+}
+
+func TestFileStore_InvalidFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "store.json")
+	if err := os.WriteFile(filename, []byte("{invalid}"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	if _, err := NewFileStore(filename).List(ScopeUser); err == nil {
+		t.Error("List() expected error for invalid file")
+	}
+}
+
+// TestWriteFile checks that writeFile replaces the target through a symlink,
+// keeps its permissions and leaves no temporary file behind.
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "profile")
+	if err := os.WriteFile(target, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, ".profile")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlink: %v", err)
+	}
+
+	if err := writeFile(link, []byte("new")); err != nil {
+		t.Fatalf("writeFile() error = %v", err)
+	}
+	if got := readFile(t, target); got != "new" {
+		t.Errorf("target = %q, want new", got)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Lstat(link) = %v, %v, want the symlink kept", info, err)
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Stat(target) = %v, %v, want mode 0600", info, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("dir has %d entries, want no temporary file left", len(entries))
+	}
+}
+
+func TestWatch(t *testing.T) {
+	base := NewMemoryStore()
+	_ = base.Set(ScopeUser, EnvVar{Key: "A", Value: "1", Type: TypeString})
+	var writes []StoreWrite
+	s := Watch(&failingStore{Store: base, n: 3}, func(w StoreWrite) { writes = append(writes, w) })
+
+	_ = s.Set(ScopeUser, EnvVar{Key: "a", Value: "2", Type: TypeString})
+	_ = s.Delete(ScopeSystem, "B")
+	if err := s.Delete(ScopeUser, "A"); !errors.Is(err, errWriteFailed) {
+		t.Fatalf("Delete() error = %v, want errWriteFailed", err)
+	}
+
+	if len(writes) != 3 {
+		t.Fatalf("onWrite called %d times, want 3", len(writes))
+	}
+	if w := writes[0]; !w.Existed || w.Old.Key != "A" || w.Old.Value != "1" || w.New == nil || w.New.Value != "2" || w.Err != nil {
+		t.Errorf("Set() reported %+v, want A 1 -> 2", w)
+	}
+	if w := writes[1]; w.Existed || w.New != nil || w.Key != "B" {
+		t.Errorf("Delete() of a missing key reported %+v", w)
+	}
+	if w := writes[2]; !errors.Is(w.Err, errWriteFailed) {
+		t.Errorf("failed Delete() reported %+v, want its error", w)
+	}
+	if got := ConventionsOf(s); got.PathKey != ConventionsOf(base).PathKey {
+		t.Errorf("ConventionsOf(Watch()) = %+v, want the base conventions", got)
+	}
+}
+
+func TestPackageFunctionsUseStore(t *testing.T) {
+	prev := CurrentStore()
+	UseStore(NewMemoryStore())
+	t.Cleanup(func() { UseStore(prev) })
+
+	if err := Set("MENV_TEST_STORE_KEY", "user-value"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := SetSystem("MENV_TEST_STORE_KEY", "system-value"); err != nil {
+		t.Fatalf("SetSystem() error = %v", err)
+	}
+
+	if got, _ := GetUser("MENV_TEST_STORE_KEY"); got != "user-value" {
+		t.Errorf("GetUser() = %q, want %q", got, "user-value")
+	}
+	if got, _ := GetSystem("MENV_TEST_STORE_KEY"); got != "system-value" {
+		t.Errorf("GetSystem() = %q, want %q", got, "system-value")
+	}
+
+	if err := Unset("MENV_TEST_STORE_KEY"); err != nil {
+		t.Fatalf("Unset() error = %v", err)
+	}
+	if err := UnsetSystem("MENV_TEST_STORE_KEY"); err != nil {
+		t.Fatalf("UnsetSystem() error = %v", err)
+	}
+	if vars, _ := ListUser(); len(vars) != 0 {
+		t.Errorf("ListUser() = %v, want empty", vars)
+	}
+	if vars, _ := ListSystem(); len(vars) != 0 {
+		t.Errorf("ListSystem() = %v, want empty", vars)
+	}
+}
+
+func TestSetVarType(t *testing.T) {
+	prev := CurrentStore()
+	store := NewMemoryStore()
+	UseStore(store)
+	t.Cleanup(func() { UseStore(prev) })
+
+	tests := []struct {
+		name     string
+		existing *EnvVar
+		set      EnvVar
+		want     ValueType
+	}{
+		{
+			name: "new plain value",
+			set:  EnvVar{Key: "A", Value: "C:\\Go"},
+			want: TypeString,
+		},
+		{
+			name: "new value with reference",
+			set:  EnvVar{Key: "B", Value: "%JAVA_HOME%\\bin"},
+			want: TypeExpandString,
+		},
+		{
+			name:     "existing expand string kept",
+			existing: &EnvVar{Key: "C", Value: "%X%", Type: TypeExpandString},
+			set:      EnvVar{Key: "C", Value: "plain"},
+			want:     TypeExpandString,
+		},
+		{
+			name:     "existing string kept",
+			existing: &EnvVar{Key: "D", Value: "x", Type: TypeString},
+			set:      EnvVar{Key: "D", Value: "%X%"},
+			want:     TypeString,
+		},
+		{
+			name:     "explicit type wins",
+			existing: &EnvVar{Key: "E", Value: "x", Type: TypeString},
+			set:      EnvVar{Key: "E", Value: "y", Type: TypeExpandString},
+			want:     TypeExpandString,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.existing != nil {
+				if err := store.Set(ScopeUser, *tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := SetVar(ScopeUser, tt.set); err != nil {
+				t.Fatalf("SetVar() error = %v", err)
+			}
+			got, _, _ := store.Get(ScopeUser, tt.set.Key)
+			if got.Type != tt.want {
+				t.Errorf("SetVar() stored type %s, want %s", got.Type, tt.want)
+			}
+		})
+	}
+}
+
+// countingStore counts the writes that reach the wrapped store.
+type countingStore struct {
+	Store
+	sets int
+}
+
+func (s *countingStore) Set(scope Scope, v EnvVar) error {
+	s.sets++
+	return s.Store.Set(scope, v)
+}
+
+func TestSetVarChange(t *testing.T) {
+	prev := CurrentStore()
+	store := &countingStore{Store: NewMemoryStore()}
+	UseStore(store)
+	t.Cleanup(func() { UseStore(prev) })
+
+	_ = store.Store.Set(ScopeUser, EnvVar{Key: "A", Value: "1", Type: TypeString})
+	_ = store.Store.Set(ScopeSystem, EnvVar{Key: "B", Value: "2", Type: TypeString})
+
+	tests := []struct {
+		name  string
+		scope Scope
+		set   EnvVar
+		want  Change
+		sets  int
+	}{
+		{name: "same value", scope: ScopeUser, set: EnvVar{Key: "A", Value: "1"}, want: Unchanged},
+		{name: "same value other case key", scope: ScopeUser, set: EnvVar{Key: "a", Value: "1"}, want: Unchanged},
+		{name: "new value", scope: ScopeUser, set: EnvVar{Key: "A", Value: "2"}, want: Updated, sets: 1},
+		{name: "new type", scope: ScopeUser, set: EnvVar{Key: "A", Value: "2", Type: TypeExpandString}, want: Updated, sets: 1},
+		{name: "set in the other scope only", scope: ScopeUser, set: EnvVar{Key: "B", Value: "2"}, want: Created, sets: 1},
+		{name: "system same value", scope: ScopeSystem, set: EnvVar{Key: "B", Value: "2"}, want: Unchanged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.sets = 0
+			got, err := SetVar(tt.scope, tt.set)
+			if err != nil {
+				t.Fatalf("SetVar() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SetVar() = %s, want %s", got, tt.want)
+			}
+			if store.sets != tt.sets {
+				t.Errorf("SetVar() wrote %d times, want %d", store.sets, tt.sets)
+			}
+		})
+	}
+}
+
+func TestChangeSummary(t *testing.T) {
+	var s ChangeSummary
+	for _, c := range []Change{Created, Updated, Unchanged, Unchanged} {
+		s.Add(c)
+	}
+	if s.Total() != 4 {
+		t.Errorf("Total() = %d, want 4", s.Total())
+	}
+	if s.Changed() != 2 {
+		t.Errorf("Changed() = %d, want 2", s.Changed())
+	}
+	if got, want := s.String(), "1 created, 1 updated, 2 unchanged"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+// failingStore fails the nth write that reaches the wrapped store, and
+// every write after it with sticky.
+type failingStore struct {
+	Store
+	n, writes int
+	sticky    bool
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (s *failingStore) write() error {
+	s.writes++
+	if s.writes == s.n || (s.sticky && s.writes > s.n) {
+		return errWriteFailed
+	}
+	return nil
+}
+
+func (s *failingStore) Set(scope Scope, v EnvVar) error {
+	if err := s.write(); err != nil {
+		return err
+	}
+	return s.Store.Set(scope, v)
+}
+
+func (s *failingStore) Delete(scope Scope, key string) error {
+	if err := s.write(); err != nil {
+		return err
+	}
+	return s.Store.Delete(scope, key)
+}
+
+func TestChangesetPlan(t *testing.T) {
+	prev := CurrentStore()
+	store := NewMemoryStore()
+	UseStore(store)
+	t.Cleanup(func() { UseStore(prev) })
+
+	_ = store.Set(ScopeUser, EnvVar{Key: "A", Value: "1", Type: TypeExpandString})
+
+	var c Changeset
+	c.Set(ScopeUser, EnvVar{Key: "A", Value: "1"})
+	c.Set(ScopeUser, EnvVar{Key: "a", Value: "2"})
+	c.Set(ScopeUser, EnvVar{Key: "B", Value: "%A%"})
+	c.Delete(ScopeUser, "B")
+	c.Delete(ScopeUser, "B")
+	c.Set(ScopeSystem, EnvVar{Key: "A", Value: "1"})
+
+	got, err := c.Plan()
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	want := []struct {
+		change Change
+		typ    ValueType
+	}{
+		{Unchanged, TypeExpandString},
+		{Updated, TypeExpandString},
+		{Created, TypeExpandString},
+		{Deleted, ""},
+		{Unchanged, ""},
+		{Created, TypeString},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Plan() = %v, want %d results", got, len(want))
+	}
+	for i, w := range want {
+		if got[i].Change != w.change || got[i].New.Type != w.typ {
+			t.Errorf("Plan()[%d] = %s %s, want %s %s", i, got[i].Change, got[i].New.Type, w.change, w.typ)
+		}
+	}
+	if vars, _ := store.List(ScopeSystem); len(vars) != 0 {
+		t.Errorf("Plan() wrote to the store: %v", vars)
+	}
+}
+
+func TestChangesetRollback(t *testing.T) {
+	initial := []EnvVar{
+		{Key: "A", Value: "1", Type: TypeString},
+		{Key: "B", Value: "2", Type: TypeString},
+	}
+
+	for n := 1; n <= 4; n++ {
+		t.Run(fmt.Sprintf("fail write %d", n), func(t *testing.T) {
+			mem := NewMemoryStore()
+			for _, v := range initial {
+				_ = mem.Set(ScopeUser, v)
+			}
+			prev := CurrentStore()
+			UseStore(&failingStore{Store: mem, n: n})
+			t.Cleanup(func() { UseStore(prev) })
+
+			var c Changeset
+			c.Set(ScopeUser, EnvVar{Key: "A", Value: "10"})
+			c.Set(ScopeUser, EnvVar{Key: "B", Value: "2"}) // unchanged, not written
+			c.Delete(ScopeUser, "B")
+			c.Set(ScopeUser, EnvVar{Key: "C", Value: "3"})
+			c.Set(ScopeUser, EnvVar{Key: "D", Value: "4"})
+
+			if _, err := c.Apply(); !errors.Is(err, errWriteFailed) {
+				t.Fatalf("Apply() error = %v, want %v", err, errWriteFailed)
+			}
+			got, _ := mem.List(ScopeUser)
+			if len(got) != len(initial) {
+				t.Fatalf("after rollback List() = %v, want %v", got, initial)
+			}
+			for i := range initial {
+				if got[i] != initial[i] {
+					t.Errorf("after rollback List()[%d] = %v, want %v", i, got[i], initial[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChangesetRollbackFailure(t *testing.T) {
+	mem := NewMemoryStore()
+	prev := CurrentStore()
+	UseStore(&failingStore{Store: mem, n: 2, sticky: true})
+	t.Cleanup(func() { UseStore(prev) })
+
+	var c Changeset
+	c.Set(ScopeUser, EnvVar{Key: "A", Value: "1"})
+	c.Set(ScopeUser, EnvVar{Key: "B", Value: "2"})
+	_, err := c.Apply()
+	if !errors.Is(err, errWriteFailed) || !strings.Contains(err.Error(), "rollback failed") {
+		t.Fatalf("Apply() error = %v, want write and rollback failures", err)
+	}
+	if _, ok, _ := mem.Get(ScopeUser, "A"); !ok {
+		t.Error("A was removed although its rollback failed")
+	}
+}
+
+func TestChangesetSetIf(t *testing.T) {
+	prev := CurrentStore()
+	store := NewMemoryStore()
+	UseStore(store)
+	t.Cleanup(func() { UseStore(prev) })
+	_ = store.Set(ScopeUser, EnvVar{Key: "Path", Value: "a", Type: TypeExpandString})
+
+	tests := []struct {
+		name    string
+		key     string
+		old     string
+		wantErr bool
+	}{
+		{name: "unchanged", key: "Path", old: "a"},
+		{name: "changed", key: "Path", old: "stale", wantErr: true},
+		{name: "unset", key: "NEW", old: ""},
+		{name: "set since", key: "Path", old: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = store.Set(ScopeUser, EnvVar{Key: "Path", Value: "a", Type: TypeExpandString})
+			_ = store.Delete(ScopeUser, "NEW")
+
+			var c Changeset
+			c.Set(ScopeUser, EnvVar{Key: "OTHER", Value: "1"})
+			c.SetIf(ScopeUser, EnvVar{Key: tt.key, Value: "b"}, tt.old)
+			_, err := c.Apply()
+			if errors.Is(err, ErrConflict) != tt.wantErr {
+				t.Fatalf("Apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			v, _, _ := store.Get(ScopeUser, tt.key)
+			_, other, _ := store.Get(ScopeUser, "OTHER")
+			if tt.wantErr && (v.Value == "b" || other) {
+				t.Errorf("Apply() wrote despite the conflict")
+			}
+			if !tt.wantErr && v.Value != "b" {
+				t.Errorf("%s = %q, want b", tt.key, v.Value)
+			}
+			_ = store.Delete(ScopeUser, "OTHER")
+		})
+	}
+}
+
+// lockingStore records whether each write was made under the lock.
+type lockingStore struct {
+	Store
+	locked   *[]Scope
+	unlocked int
+}
+
+func (s *lockingStore) Set(scope Scope, v EnvVar) error {
+	if !slices.Contains(*s.locked, scope) {
+		s.unlocked++
+	}
+	return s.Store.Set(scope, v)
+}
+
+func TestChangesetApplyLocks(t *testing.T) {
+	var locked, taken []Scope
+	UseLocker(func(scope Scope) (func(), error) {
+		taken = append(taken, scope)
+		locked = append(locked, scope)
+		return func() { locked = slices.DeleteFunc(locked, func(s Scope) bool { return s == scope }) }, nil
+	})
+	t.Cleanup(func() { UseLocker(nil) })
+	store := &lockingStore{Store: NewMemoryStore(), locked: &locked}
+	prev := CurrentStore()
+	UseStore(store)
+	t.Cleanup(func() { UseStore(prev) })
+
+	release, err := Lock(ScopeSystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Changeset
+	c.Set(ScopeSystem, EnvVar{Key: "A", Value: "1"})
+	c.Set(ScopeUser, EnvVar{Key: "A", Value: "1"})
+	if _, err := c.Apply(); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	release()
+
+	if store.unlocked != 0 {
+		t.Errorf("%d write(s) made without the lock", store.unlocked)
+	}
+	if want := []Scope{ScopeSystem, ScopeUser}; !slices.Equal(taken, want) {
+		t.Errorf("locks taken = %v, want %v: each once, nested calls reusing a held lock", taken, want)
+	}
+	if len(locked) != 0 {
+		t.Errorf("locks still held after release: %v", locked)
+	}
+}
+
+func TestLockError(t *testing.T) {
+	errBusy := errors.New("busy")
+	released := 0
+	UseLocker(func(scope Scope) (func(), error) {
+		if scope == ScopeSystem {
+			return nil, errBusy
+		}
+		return func() { released++ }, nil
+	})
+	t.Cleanup(func() { UseLocker(nil) })
+	prev := CurrentStore()
+	store := NewMemoryStore()
+	UseStore(store)
+	t.Cleanup(func() { UseStore(prev) })
+
+	var c Changeset
+	c.Set(ScopeUser, EnvVar{Key: "A", Value: "1"})
+	c.Set(ScopeSystem, EnvVar{Key: "A", Value: "1"})
+	if _, err := c.Apply(); !errors.Is(err, errBusy) {
+		t.Fatalf("Apply() error = %v, want %v", err, errBusy)
+	}
+	if released != 1 {
+		t.Errorf("user lock released %d times, want 1", released)
+	}
+	if _, ok, _ := store.Get(ScopeUser, "A"); ok {
+		t.Error("Apply() wrote without the lock")
+	}
+}
@@ -1,47 +1,95 @@
 package env
 
 import (
-	"os"
-	"os/exec"
-
 	"github.com/doraemonkeys/menv/color"
 )
 
-// Set sets a user environment variable using setx command.
+// Set sets a user environment variable in the current store.
+// An existing variable keeps its value type.
 func Set(key, value string) error {
-	if os.Getenv(key) == value {
-		color.Warning("skip %s=%s", key, value)
-		return nil
+	_, err := SetVar(ScopeUser, EnvVar{Key: key, Value: value})
+	return err
+}
+
+// SetVar sets an environment variable of the scope in the current store
+// and reports whether it was created, updated or already set. An empty
+// Type keeps the type of the existing variable; new variables referencing
+// %VAR% are stored as REG_EXPAND_SZ, others as REG_SZ.
+func SetVar(scope Scope, v EnvVar) (Change, error) {
+	change, err := setVar(scope, v)
+	if err != nil {
+		return change, err
 	}
-	cmd := exec.Command("setx", key, value)
-	if err := cmd.Run(); err != nil {
-		return err
+
+	if change == Unchanged {
+		color.Warning("skip %s=%s%s (unchanged)", v.Key, v.Value, scopeSuffix(scope))
+	} else {
+		color.Done("set  %s=%s%s (%s)", v.Key, v.Value, scopeSuffix(scope), change)
 	}
-	color.Success("set  %s=%s", key, value)
-	return nil
+	return change, nil
 }
 
-// SetPS sets a user environment variable using PowerShell.
-// This method handles keys and values containing '&' correctly.
+// SetPS sets a user environment variable.
+// It is kept for compatibility and is equivalent to Set now that all
+// writes go through the current store.
 func SetPS(key, value string) error {
-	if os.Getenv(key) == value {
-		color.Warning("skip %s=%s", key, value)
-		return nil
-	}
-	cmd := exec.Command("powershell", "[System.Environment]::SetEnvironmentVariable(\""+key+"\", \""+value+"\", \"User\")")
-	if err := cmd.Run(); err != nil {
-		return err
-	}
-	color.Success("set  %s=%s", key, value)
-	return nil
+	return Set(key, value)
 }
 
 // Unset removes a user environment variable.
 func Unset(key string) error {
-	cmd := exec.Command("reg", "delete", "HKEY_CURRENT_USER\\Environment", "/F", "/V", key)
-	if err := cmd.Run(); err != nil {
+	return UnsetVar(ScopeUser, key)
+}
+
+// UnsetVar removes an environment variable of the scope from the current store.
+func UnsetVar(scope Scope, key string) error {
+	release, err := Lock(scope)
+	if err != nil {
 		return err
 	}
-	color.Success("unset %s", key)
+	err = current.Delete(scope, key)
+	release()
+	if err != nil {
+		return err
+	}
+	color.Done("unset %s%s", key, scopeSuffix(scope))
 	return nil
 }
+
+// ApplyChanges applies c, printing each change like SetVar and UnsetVar.
+// Nothing is printed if c fails and is rolled back.
+func ApplyChanges(c *Changeset) (ChangeSummary, error) {
+	var summary ChangeSummary
+	results, err := c.Apply()
+	if err != nil {
+		return summary, err
+	}
+	for _, r := range results {
+		printChange(r)
+		summary.Add(r.Change)
+	}
+	return summary, nil
+}
+
+func printChange(r ChangeResult) {
+	switch r.Change {
+	case Deleted:
+		color.Done("unset %s%s", r.New.Key, scopeSuffix(r.Scope))
+	case Created, Updated:
+		color.Done("set  %s=%s%s (%s)", r.New.Key, r.New.Value, scopeSuffix(r.Scope), r.Change)
+	default:
+		if r.Existed {
+			color.Warning("skip %s=%s%s (unchanged)", r.New.Key, r.New.Value, scopeSuffix(r.Scope))
+		} else {
+			color.Warning("skip unset %s%s (not set)", r.New.Key, scopeSuffix(r.Scope))
+		}
+	}
+}
+
+// scopeSuffix returns " [system]" for the system scope, for messages.
+func scopeSuffix(scope Scope) string {
+	if scope == ScopeSystem {
+		return " [system]"
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/env"
+)
+
+// printExpansion prints value with its references expanded against the
+// store, warning about undefined references and reference cycles.
+func printExpansion(key, value string, scope env.Scope) error {
+	x, err := env.NewExpander(scope)
+	if err != nil {
+		return err
+	}
+	result := x.Expand(value)
+
+	fmt.Printf("%s%s%s=%s  %s(expanded)%s\n", color.Green, key, color.Reset, result.Value, color.Blue, color.Reset)
+	for _, name := range result.Undefined {
+		color.Warning("undefined reference: %s", name)
+	}
+	for _, cycle := range result.Cycles {
+		color.Warning("reference cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestPrintExpansion(t *testing.T) {
+	useStore(t,
+		env.EnvVar{Key: "A", Value: "%B%", Type: env.TypeExpandString},
+		env.EnvVar{Key: "B", Value: "%A%", Type: env.TypeExpandString},
+		env.EnvVar{Key: "JAVA_HOME", Value: `C:\jdk`},
+	)
+
+	for _, value := range []string{`%JAVA_HOME%\bin`, `%MISSING%\bin`, "%A%"} {
+		if err := printExpansion("K", value, env.ScopeUser); err != nil {
+			t.Errorf("printExpansion(%q) error = %v", value, err)
+		}
+	}
+}
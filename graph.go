@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/env"
+)
+
+// showGraph prints the variable reference graph as a tree followed by its
+// dangling references and cycles, or as Graphviz DOT with -dot.
+func showGraph() error {
+	g, err := env.BuildGraph()
+	if err != nil {
+		return err
+	}
+	if *cmd.Dot {
+		return g.WriteDOT(os.Stdout)
+	}
+
+	color.Info("Variable references (user + system):")
+	fmt.Println()
+	if err := g.WriteTree(os.Stdout); err != nil {
+		return err
+	}
+
+	dangling := g.Dangling()
+	cycles := g.Cycles()
+	if len(dangling) == 0 && len(cycles) == 0 {
+		fmt.Println()
+		color.Success("no dangling references or cycles")
+		return nil
+	}
+
+	if len(dangling) > 0 {
+		fmt.Println()
+		color.Warning("Dangling references (%d):", len(dangling))
+		for _, i := range dangling {
+			n := g.Nodes[i]
+			var names []string
+			for _, r := range n.Refs {
+				if r.Dangling() {
+					names = append(names, r.Name)
+				}
+			}
+			fmt.Printf("  %s -> %s\n", n.Label(), strings.Join(names, ", "))
+		}
+	}
+
+	if len(cycles) > 0 {
+		fmt.Println()
+		color.Warning("Reference cycles (%d):", len(cycles))
+		for _, cycle := range cycles {
+			labels := make([]string, len(cycle))
+			for k, i := range cycle {
+				labels[k] = g.Nodes[i].Label()
+			}
+			fmt.Printf("  %s\n", strings.Join(labels, " -> "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestShowGraph(t *testing.T) {
+	clean := []env.EnvVar{
+		{Key: "JAVA_HOME", Value: `C:\jdk`},
+		{Key: "Path", Value: `%JAVA_HOME%\bin`, Type: env.TypeExpandString},
+	}
+	broken := []env.EnvVar{
+		{Key: "A", Value: "%B%", Type: env.TypeExpandString},
+		{Key: "B", Value: "%A%", Type: env.TypeExpandString},
+		{Key: "Path", Value: `%MISSING%\bin`, Type: env.TypeExpandString},
+	}
+	for _, vars := range [][]env.EnvVar{clean, broken} {
+		useStore(t, vars...)
+		if err := showGraph(); err != nil {
+			t.Errorf("showGraph() error = %v", err)
+		}
+		setFlag(t, cmd.Dot, true)
+		if err := showGraph(); err != nil {
+			t.Errorf("showGraph(-dot) error = %v", err)
+		}
+		setFlag(t, cmd.Dot, false)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/dryrun"
+	"github.com/doraemonkeys/menv/env"
+	"github.com/doraemonkeys/menv/journal"
+	"github.com/doraemonkeys/menv/path"
+)
+
+// recorder is the journal store wrapping the current store, or nil if the
+// journal is unavailable.
+var recorder *journal.Store
+
+// useJournal records the writes of this run in the journal.
+func useJournal() {
+	filename, err := journal.DefaultPath()
+	if err != nil {
+		color.Warning("changes will not be recorded: %v", err)
+		return
+	}
+	command := "menv " + strings.Join(os.Args[1:], " ")
+	recorder = journal.Open(filename).Record(env.CurrentStore(), *cmd.StoreSpec, command)
+	env.UseStore(recorder)
+}
+
+// undoChanges handles -undo [N].
+func undoChanges(args []string) error {
+	if recorder == nil {
+		return fmt.Errorf("the journal is unavailable")
+	}
+	n := 1
+	switch len(args) {
+	case 0:
+	case 1:
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			return fmt.Errorf("invalid number of operations to undo: %s", args[0])
+		}
+	default:
+		return fmt.Errorf("unexpected arguments: %v", args)
+	}
+
+	ops, err := recorder.Undo(n, *cmd.Force)
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		color.Info("Undid %s  %s", op.Time.Format(time.DateTime), op.Command)
+	}
+	return nil
+}
+
+// showHistory handles -history: it lists the recorded operations, newest
+// first, or with a key the timeline of that variable.
+func showHistory(args []string) error {
+	switch {
+	case len(args) == 1:
+		return showKeyHistory(args[0])
+	case len(args) > 1:
+		return fmt.Errorf("unexpected arguments: %v", args)
+	case *cmd.AsOf != "":
+		return fmt.Errorf("usage: menv -history -as-of <time> KEY")
+	}
+	filename, err := journal.DefaultPath()
+	if err != nil {
+		return err
+	}
+	ops, err := journal.Open(filename).Operations(*cmd.StoreSpec)
+	if err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		color.Info("No changes recorded")
+		return nil
+	}
+
+	undone := journal.Undone(ops)
+	conv := path.Conventions()
+	for i := len(ops) - 1; i >= 0; i-- {
+		op := ops[i]
+		note := ""
+		switch {
+		case undone[op.ID]:
+			note = color.Sprintf(color.Yellow, " (undone)")
+		case len(op.Undoes) > 0:
+			note = color.Sprintf(color.Yellow, " (undo)")
+		}
+		fmt.Printf("%s%3d%s  %s  %s%s\n", color.Cyan, len(ops)-i, color.Reset,
+			op.Time.Format(time.DateTime), op.Command, note)
+
+		changes := make([]env.ChangeResult, len(op.Entries))
+		for j, e := range op.Entries {
+			changes[j] = e.Change()
+		}
+		var b strings.Builder
+		dryrun.Write(&b, changes, conv)
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(b.String(), "\n"), "\n") {
+			fmt.Print("       ", line)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// keyHistory returns the journal entries of key in the selected scope.
+func keyHistory(key string) ([]journal.Entry, error) {
+	filename, err := journal.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	conv := env.ConventionsOf(env.CurrentStore())
+	return journal.Open(filename).History(*cmd.StoreSpec, env.ScopeOf(*cmd.SetSystem), key, conv)
+}
+
+// showKeyHistory handles -history KEY: it prints the values of key over
+// time, entry by entry for PATH, or with -as-of the value at that time.
+func showKeyHistory(key string) error {
+	history, err := keyHistory(key)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		color.Info("No changes recorded for %s", key)
+		return nil
+	}
+
+	if *cmd.AsOf != "" {
+		v, ok, err := valueAt(history)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			color.Warning("%s was not set", key)
+			return nil
+		}
+		fmt.Println(v.Value)
+		return nil
+	}
+
+	conv := path.Conventions()
+	isList := strings.EqualFold(key, conv.PathKey)
+	for _, e := range history {
+		when := color.Sprintf(color.Cyan, "%s", e.Time.Format(time.DateTime))
+		by := color.Sprintf(color.Blue, "(%s)", e.Command)
+		switch {
+		case isList:
+			for _, line := range entryChanges(e, conv.ListSeparator) {
+				fmt.Printf("%s  %s  %s\n", when, line, by)
+			}
+		case e.New == nil:
+			fmt.Printf("%s  unset  %s\n", when, by)
+		default:
+			fmt.Printf("%s  %s  %s\n", when, e.New.Value, by)
+		}
+	}
+	return nil
+}
+
+// entryChanges returns the entries added (+) and removed (-) by a write of
+// a list variable, or a note if they were only reordered.
+func entryChanges(e journal.Entry, sep string) []string {
+	var before, after []string
+	if e.Old != nil {
+		before = strings.Split(e.Old.Value, sep)
+	}
+	if e.New != nil {
+		after = strings.Split(e.New.Value, sep)
+	}
+	var lines []string
+	for _, p := range after {
+		if p != "" && !slices.Contains(before, p) {
+			lines = append(lines, "+ "+p)
+		}
+	}
+	for _, p := range before {
+		if p != "" && !slices.Contains(after, p) {
+			lines = append(lines, "- "+p)
+		}
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "~ reordered")
+	}
+	return lines
+}
+
+// valueAt returns the value of a variable at the time given with -as-of.
+func valueAt(history []journal.Entry) (env.EnvVar, bool, error) {
+	t, err := journal.ParseTime(*cmd.AsOf, time.Now())
+	if err != nil {
+		return env.EnvVar{}, false, err
+	}
+	v, ok, _ := journal.ValueAt(history, t)
+	return v, ok, nil
+}
+
+// revertValue handles -revert KEY -as-of <time>: it shows the change and,
+// once confirmed, sets key back to its value at that time, or deletes it
+// if it was not set then.
+func revertValue(key string, args []string) error {
+	if len(args) != 0 || *cmd.AsOf == "" {
+		return fmt.Errorf("usage: menv -revert KEY -as-of <time>")
+	}
+	history, err := keyHistory(key)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return fmt.Errorf("no changes recorded for %s", key)
+	}
+	v, ok, err := valueAt(history)
+	if err != nil {
+		return err
+	}
+
+	scope := env.ScopeOf(*cmd.SetSystem)
+	var c env.Changeset
+	if ok {
+		c.Set(scope, v)
+	} else {
+		c.Delete(scope, key)
+	}
+	plan, err := c.Plan()
+	if err != nil {
+		return err
+	}
+	if plan[0].Change == env.Unchanged {
+		color.Info("%s already has its value as of %s", key, *cmd.AsOf)
+		return nil
+	}
+
+	// A dry run prints the same change once it is done.
+	if !*cmd.DryRun && !*cmd.CheckOnly {
+		dryrun.Write(os.Stdout, plan, path.Conventions())
+	}
+	if !*cmd.Yes && !confirmAction(fmt.Sprintf("Revert %s to its value as of %s?", key, *cmd.AsOf)) {
+		color.Warning("Cancelled")
+		return nil
+	}
+	// The previewed change is made as is, so that a change made to the
+	// variable in the meantime fails with a conflict.
+	_, err = env.ApplyChanges(env.PlannedChanges(plan))
+	return err
+}
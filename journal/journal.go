@@ -0,0 +1,371 @@
+// Package journal records every write to an env store in a local file, so
+// that changes can be listed and undone.
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/env"
+	"github.com/doraemonkeys/menv/jsonl"
+)
+
+// Entry is one recorded write. Old is nil if the variable did not exist,
+// New is nil if it was deleted.
+type Entry struct {
+	Op      int64       `json:"op"`
+	Time    time.Time   `json:"time"`
+	Command string      `json:"command,omitempty"`
+	Store   string      `json:"store,omitempty"`
+	Undoes  []int64     `json:"undoes,omitempty"`
+	Scope   string      `json:"scope"`
+	Key     string      `json:"key"`
+	Old     *env.EnvVar `json:"old,omitempty"`
+	New     *env.EnvVar `json:"new,omitempty"`
+}
+
+// Change returns the entry as a change of the store.
+func (e Entry) Change() env.ChangeResult {
+	r := env.ChangeResult{Scope: e.scope(), New: env.EnvVar{Key: e.Key}}
+	if e.Old != nil {
+		r.Old, r.Existed = *e.Old, true
+	}
+	switch {
+	case e.New == nil:
+		r.Change = env.Deleted
+	case e.Old == nil:
+		r.Change, r.New = env.Created, *e.New
+	default:
+		r.Change, r.New = env.Updated, *e.New
+	}
+	return r
+}
+
+func (e Entry) scope() env.Scope {
+	return env.ScopeOf(e.Scope == env.ScopeSystem.String())
+}
+
+// Operation is the writes made by one run of menv.
+type Operation struct {
+	ID      int64
+	Time    time.Time
+	Command string
+	Store   string
+	// Undoes are the operations this one reverted, for -undo.
+	Undoes  []int64
+	Entries []Entry
+}
+
+// Journal is a file of entries, one JSON object per line.
+type Journal struct {
+	path string
+}
+
+// Open returns the journal kept in the file at path.
+func Open(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// DefaultPath returns $MENV_JOURNAL, or journal.jsonl in the menv directory
+// of the user's config directory.
+func DefaultPath() (string, error) {
+	if p := os.Getenv("MENV_JOURNAL"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "menv", "journal.jsonl"), nil
+}
+
+// Append adds e to the end of the journal.
+func (j *Journal) Append(e Entry) error {
+	return jsonl.Append(j.path, e)
+}
+
+// Operations returns the operations recorded for the store spec, oldest
+// first. A missing journal has no operations.
+func (j *Journal) Operations(store string) ([]Operation, error) {
+	var ops []Operation
+	err := jsonl.Scan(j.path, func(e Entry) {
+		if e.Store != store {
+			return
+		}
+		if n := len(ops); n > 0 && ops[n-1].ID == e.Op {
+			ops[n-1].Entries = append(ops[n-1].Entries, e)
+			return
+		}
+		ops = append(ops, Operation{
+			ID: e.Op, Time: e.Time, Command: e.Command, Store: e.Store,
+			Undoes: e.Undoes, Entries: []Entry{e},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// Undone returns the IDs of the operations reverted by later ones.
+func Undone(ops []Operation) map[int64]bool {
+	undone := make(map[int64]bool)
+	for _, op := range ops {
+		for _, id := range op.Undoes {
+			undone[id] = true
+		}
+	}
+	return undone
+}
+
+// History returns the entries recorded for key in scope of the store spec,
+// oldest first. Keys are compared following conv.
+func (j *Journal) History(store string, scope env.Scope, key string, conv env.Conventions) ([]Entry, error) {
+	ops, err := j.Operations(store)
+	if err != nil {
+		return nil, err
+	}
+	var result []Entry
+	for _, op := range ops {
+		for _, e := range op.Entries {
+			if e.scope() != scope {
+				continue
+			}
+			if e.Key == key || !conv.CaseSensitive && strings.EqualFold(e.Key, key) {
+				result = append(result, e)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ValueAt returns the variable as of t according to its history: the value
+// of the last entry made by t, or the value the first entry replaced if
+// they were all made later. ok is false if the variable was not set;
+// known is false if history is empty.
+func ValueAt(history []Entry, t time.Time) (v env.EnvVar, ok, known bool) {
+	if len(history) == 0 {
+		return env.EnvVar{}, false, false
+	}
+	state := history[0].Old
+	for _, e := range history {
+		if e.Time.After(t) {
+			break
+		}
+		state = e.New
+	}
+	if state == nil {
+		return env.EnvVar{}, false, true
+	}
+	return *state, true, true
+}
+
+// ParseTime parses a point in time given as a date, a date and time, an
+// RFC 3339 timestamp, or a duration before now like 36h or 7d. Dates and
+// times without a zone are local.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateTime, "2006-01-02 15:04", "2006-01-02T15:04:05", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use e.g. 2006-01-02, \"2006-01-02 15:04\" or 7d", s)
+}
+
+// ParseUntil parses the end of a time range like ParseTime, except that a
+// date or a time without seconds stands for its last instant: 2026-10-18
+// includes the whole day.
+func ParseUntil(s string, now time.Time) (time.Time, error) {
+	t, err := ParseTime(s, now)
+	if err != nil {
+		return t, err
+	}
+	if _, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	if _, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+		return t.Add(time.Minute - time.Nanosecond), nil
+	}
+	return t, nil
+}
+
+// Store is an env.Store that records its writes in a journal as one
+// operation.
+type Store struct {
+	*env.WatchedStore
+	journal *Journal
+	op      Operation
+	// held keeps entries back from the journal while an undo is applied.
+	held *[]Entry
+}
+
+// Record returns a Store writing to base and recording the writes in j
+// under a new operation. store is the spec of base, so that operations on
+// different stores are kept apart, and command is shown by -history.
+func (j *Journal) Record(base env.Store, store, command string) *Store {
+	now := time.Now()
+	s := &Store{
+		journal: j,
+		op:      Operation{ID: nextID(now), Time: now, Command: command, Store: store},
+	}
+	s.WatchedStore = env.Watch(base, s.record)
+	return s
+}
+
+var lastID atomic.Int64
+
+// nextID returns an operation ID from now, kept increasing for clocks too
+// coarse to tell operations apart.
+func nextID(now time.Time) int64 {
+	for {
+		last := lastID.Load()
+		id := max(now.UnixNano(), last+1)
+		if lastID.CompareAndSwap(last, id) {
+			return id
+		}
+	}
+}
+
+// record appends a successful write to the journal. The write has already
+// been made, so a journal that cannot be written only causes a warning.
+func (s *Store) record(w env.StoreWrite) {
+	if w.Err != nil {
+		return
+	}
+	e := Entry{
+		Op: s.op.ID, Time: time.Now(), Command: s.op.Command, Store: s.op.Store,
+		Undoes: s.op.Undoes, Scope: w.Scope.String(), Key: w.Key, New: w.New,
+	}
+	if w.Existed {
+		e.Key = w.Old.Key
+		e.Old = &w.Old
+	}
+	if s.held != nil {
+		*s.held = append(*s.held, e)
+		return
+	}
+	s.append(e)
+}
+
+func (s *Store) append(e Entry) {
+	if err := s.journal.Append(e); err != nil {
+		color.Warning("cannot record %s in the journal: %v", e.Key, err)
+	}
+}
+
+// Undo reverts the last n operations that have not been undone yet, newest
+// first, as one changeset through the current store. A variable changed
+// since the operation being undone is refused unless force is set.
+func (s *Store) Undo(n int, force bool) ([]Operation, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of operations to undo: %d", n)
+	}
+	ops, err := s.journal.Operations(s.op.Store)
+	if err != nil {
+		return nil, err
+	}
+	undone := Undone(ops)
+
+	var targets []Operation
+	for i := len(ops) - 1; i >= 0 && len(targets) < n; i-- {
+		if len(ops[i].Undoes) == 0 && !undone[ops[i].ID] {
+			targets = append(targets, ops[i])
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("nothing to undo")
+	}
+
+	// The values are compared with the store and written under one lock.
+	var scopes []env.Scope
+	for _, op := range targets {
+		for _, e := range op.Entries {
+			scopes = append(scopes, e.scope())
+		}
+	}
+	release, err := env.Lock(scopes...)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	c, err := revert(targets, force)
+	if err != nil {
+		return nil, err
+	}
+	// The writes are only marked as an undo once all of them are made; a
+	// failed undo is recorded as the writes and their rollback.
+	var held []Entry
+	s.held = &held
+	_, err = env.ApplyChanges(c)
+	s.held = nil
+	if err == nil {
+		for _, op := range targets {
+			s.op.Undoes = append(s.op.Undoes, op.ID)
+		}
+	}
+	for _, e := range held {
+		e.Undoes = s.op.Undoes
+		s.append(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// revert returns the changeset restoring the values the operations, newest
+// first, replaced.
+func revert(ops []Operation, force bool) (*env.Changeset, error) {
+	store := env.CurrentStore()
+	conv := env.ConventionsOf(store)
+	checked := make(map[string]bool)
+
+	var c env.Changeset
+	for _, op := range ops {
+		for _, e := range slices.Backward(op.Entries) {
+			id := e.Scope + "\x00" + e.Key
+			if !conv.CaseSensitive {
+				id = strings.ToLower(id)
+			}
+			// Only the newest write of a key can be compared with the
+			// store; older ones are overwritten by the changeset itself.
+			// The key is not compared, as the store may keep a spelling
+			// other than the one written.
+			if !checked[id] && !force {
+				cur, ok, err := store.Get(e.scope(), e.Key)
+				if err != nil {
+					return nil, err
+				}
+				if ok != (e.New != nil) || ok && (cur.Value != e.New.Value || cur.Type != e.New.Type) {
+					return nil, fmt.Errorf("%s [%s] has changed since %s, use -force to undo anyway",
+						e.Key, e.Scope, op.Time.Format(time.DateTime))
+				}
+			}
+			checked[id] = true
+
+			if e.Old == nil {
+				c.Delete(e.scope(), e.Key)
+			} else {
+				c.Set(e.scope(), *e.Old)
+			}
+		}
+	}
+	return &c, nil
+}
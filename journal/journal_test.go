@@ -0,0 +1,302 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doraemonkeys/menv/env"
+)
+
+// record runs write against a store recording into j, as one run of menv.
+func record(t *testing.T, j *Journal, base env.Store, write func() error) *Store {
+	t.Helper()
+	prev := env.CurrentStore()
+	s := j.Record(base, "memory", "menv test")
+	env.UseStore(s)
+	t.Cleanup(func() { env.UseStore(prev) })
+	if err := write(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStore_Records(t *testing.T) {
+	j := Open(filepath.Join(t.TempDir(), "journal.jsonl"))
+	base := env.NewMemoryStore()
+	_ = base.Set(env.ScopeUser, env.EnvVar{Key: "Path", Value: `C:\a`, Type: env.TypeExpandString})
+
+	record(t, j, base, func() error {
+		if err := env.Set("FOO", "1"); err != nil {
+			return err
+		}
+		if err := env.Set("FOO", "1"); err != nil { // unchanged, not written
+			return err
+		}
+		if err := env.SetSystem("path", `C:\sys`); err != nil {
+			return err
+		}
+		return env.Unset("Path")
+	})
+	record(t, j, base, func() error { return env.Set("FOO", "2") })
+
+	ops, err := j.Operations("memory")
+	if err != nil {
+		t.Fatalf("Operations() error = %v", err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("Operations() = %d operations, want 2", len(ops))
+	}
+
+	var got []string
+	for _, e := range ops[0].Entries {
+		c := e.Change()
+		got = append(got, c.Change.String()+" "+c.Scope.String()+" "+c.New.Key)
+	}
+	want := []string{"created user FOO", "created system path", "deleted user Path"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("first operation = %v, want %v", got, want)
+	}
+	if e := ops[0].Entries[2]; e.Old == nil || e.Old.Value != `C:\a` || e.Old.Type != env.TypeExpandString {
+		t.Errorf("deleted entry Old = %v, want the previous Path", e.Old)
+	}
+	if e := ops[1].Entries[0]; e.Old.Value != "1" || e.New.Value != "2" {
+		t.Errorf("second operation = %v -> %v, want 1 -> 2", e.Old, e.New)
+	}
+
+	// Operations are kept apart by store.
+	if ops, _ := j.Operations("file:env.json"); len(ops) != 0 {
+		t.Errorf("Operations(other store) = %v, want none", ops)
+	}
+}
+
+func TestStore_Undo(t *testing.T) {
+	j := Open(filepath.Join(t.TempDir(), "journal.jsonl"))
+	base := env.NewMemoryStore()
+	_ = base.Set(env.ScopeUser, env.EnvVar{Key: "A", Value: "0", Type: env.TypeString})
+
+	record(t, j, base, func() error { return env.Set("A", "1") })
+	record(t, j, base, func() error {
+		if err := env.Set("A", "2"); err != nil {
+			return err
+		}
+		return env.Set("B", "new")
+	})
+
+	s := record(t, j, base, func() error { return nil })
+	ops, err := s.Undo(1, false)
+	if err != nil {
+		t.Fatalf("Undo(1) error = %v", err)
+	}
+	if len(ops) != 1 || len(ops[0].Entries) != 2 {
+		t.Fatalf("Undo(1) = %v, want the second operation", ops)
+	}
+	if v, _, _ := base.Get(env.ScopeUser, "A"); v.Value != "1" {
+		t.Errorf("A = %q after Undo(1), want 1", v.Value)
+	}
+	if _, ok, _ := base.Get(env.ScopeUser, "B"); ok {
+		t.Error("B exists after Undo(1), want deleted")
+	}
+
+	// The undo is recorded, and the next undo goes further back.
+	s = record(t, j, base, func() error { return nil })
+	if _, err := s.Undo(5, false); err != nil {
+		t.Fatalf("Undo(5) error = %v", err)
+	}
+	if v, _, _ := base.Get(env.ScopeUser, "A"); v.Value != "0" || v.Type != env.TypeString {
+		t.Errorf("A = %v after Undo(5), want 0 REG_SZ", v)
+	}
+
+	s = record(t, j, base, func() error { return nil })
+	if _, err := s.Undo(1, false); err == nil {
+		t.Error("Undo() with nothing left to undo expected error")
+	}
+
+	all, _ := j.Operations("memory")
+	if undone := Undone(all); len(undone) != 2 {
+		t.Errorf("Undone() = %v, want both operations", undone)
+	}
+}
+
+func TestStore_UndoChangedSince(t *testing.T) {
+	j := Open(filepath.Join(t.TempDir(), "journal.jsonl"))
+	base := env.NewMemoryStore()
+
+	record(t, j, base, func() error { return env.Set("A", "1") })
+	// Changed outside menv.
+	_ = base.Set(env.ScopeUser, env.EnvVar{Key: "A", Value: "edited", Type: env.TypeString})
+
+	s := record(t, j, base, func() error { return nil })
+	if _, err := s.Undo(1, false); err == nil || !strings.Contains(err.Error(), "has changed") {
+		t.Fatalf("Undo() error = %v, want changed since", err)
+	}
+	if v, _, _ := base.Get(env.ScopeUser, "A"); v.Value != "edited" {
+		t.Errorf("A = %q after refused undo, want edited", v.Value)
+	}
+
+	if _, err := s.Undo(1, true); err != nil {
+		t.Fatalf("Undo(force) error = %v", err)
+	}
+	if _, ok, _ := base.Get(env.ScopeUser, "A"); ok {
+		t.Error("A exists after forced undo, want deleted")
+	}
+}
+
+// TestStore_UndoOtherSpelling checks that a write under another spelling of
+// an existing key, which the store keeps as it was, can be undone.
+func TestStore_UndoOtherSpelling(t *testing.T) {
+	j := Open(filepath.Join(t.TempDir(), "journal.jsonl"))
+	base := env.NewMemoryStore()
+	_ = base.Set(env.ScopeUser, env.EnvVar{Key: "JAVA_HOME", Value: "a", Type: env.TypeString})
+
+	record(t, j, base, func() error { return env.Set("java_home", "b") })
+
+	s := record(t, j, base, func() error { return nil })
+	if _, err := s.Undo(1, false); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if v, _, _ := base.Get(env.ScopeUser, "JAVA_HOME"); v.Value != "a" {
+		t.Errorf("JAVA_HOME = %q after Undo(), want a", v.Value)
+	}
+}
+
+func TestJournal_Operations(t *testing.T) {
+	dir := t.TempDir()
+	if ops, err := Open(filepath.Join(dir, "missing.jsonl")).Operations(""); err != nil || ops != nil {
+		t.Errorf("Operations() of missing journal = %v, %v, want none", ops, err)
+	}
+
+	filename := filepath.Join(dir, "bad.jsonl")
+	if err := os.WriteFile(filename, []byte("{\"op\":1}\n\n{bad\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(filename).Operations(""); err == nil || !strings.Contains(err.Error(), ":3:") {
+		t.Errorf("Operations() error = %v, want error on line 3", err)
+	}
+}
+
+func TestJournal_History(t *testing.T) {
+	j := Open(filepath.Join(t.TempDir(), "journal.jsonl"))
+	base := env.NewMemoryStore()
+
+	record(t, j, base, func() error { return env.Set("JAVA_HOME", "jdk17") })
+	record(t, j, base, func() error {
+		if err := env.SetSystem("JAVA_HOME", "sys"); err != nil {
+			return err
+		}
+		return env.Set("java_home", "jdk21")
+	})
+	record(t, j, base, func() error { return env.Unset("JAVA_HOME") })
+
+	history, err := j.History("memory", env.ScopeUser, "Java_Home", env.ConventionsOf(base))
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	var got []string
+	for _, e := range history {
+		if e.New == nil {
+			got = append(got, "unset")
+		} else {
+			got = append(got, e.New.Value)
+		}
+	}
+	if want := "jdk17 jdk21 unset"; strings.Join(got, " ") != want {
+		t.Errorf("History() = %v, want %s", got, want)
+	}
+}
+
+func TestValueAt(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC) }
+	history := []Entry{
+		{Time: at(10), New: &env.EnvVar{Key: "A", Value: "1"}},
+		{Time: at(12), Old: &env.EnvVar{Key: "A", Value: "1"}, New: &env.EnvVar{Key: "A", Value: "2"}},
+		{Time: at(14), Old: &env.EnvVar{Key: "A", Value: "2"}},
+	}
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		want   string
+		wantOK bool
+	}{
+		{name: "before first change", t: at(9)},
+		{name: "at a change", t: at(10), want: "1", wantOK: true},
+		{name: "between changes", t: at(13), want: "2", wantOK: true},
+		{name: "after deletion", t: at(15)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok, known := ValueAt(history, tt.t)
+			if !known || ok != tt.wantOK || v.Value != tt.want {
+				t.Errorf("ValueAt() = %v, %v, %v, want %q, %v, true", v, ok, known, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	if _, _, known := ValueAt(nil, at(10)); known {
+		t.Error("ValueAt() of empty history is known")
+	}
+	// A variable that existed before its first recorded change.
+	v, ok, _ := ValueAt(history[1:], at(11))
+	if !ok || v.Value != "1" {
+		t.Errorf("ValueAt() before first change = %v, %v, want 1", v, ok)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "7d", want: now.AddDate(0, 0, -7)},
+		{in: "36h", want: now.Add(-36 * time.Hour)},
+		{in: "2026-10-01", want: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)},
+		{in: "2026-10-01 09:30", want: time.Date(2026, 10, 1, 9, 30, 0, 0, time.Local)},
+		{in: "2026-10-01 09:30:15", want: time.Date(2026, 10, 1, 9, 30, 15, 0, time.Local)},
+		{in: "2026-10-01T09:30:00Z", want: time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)},
+		{in: "-3d", wantErr: true},
+		{in: "last week", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTime(tt.in, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTime(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("ParseTime(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUntil(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{in: "2026-10-18", want: time.Date(2026, 10, 18, 23, 59, 59, 999999999, time.Local)},
+		{in: "2026-10-18 09:30", want: time.Date(2026, 10, 18, 9, 30, 59, 999999999, time.Local)},
+		{in: "2026-10-18 09:30:15", want: time.Date(2026, 10, 18, 9, 30, 15, 0, time.Local)},
+		{in: "7d", want: now.AddDate(0, 0, -7)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseUntil(tt.in, now)
+			if err != nil {
+				t.Fatalf("ParseUntil(%q) error = %v", tt.in, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseUntil(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+	if _, err := ParseUntil("tomorrow", now); err == nil {
+		t.Error("ParseUntil(\"tomorrow\") error = nil, want an error")
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+	"github.com/doraemonkeys/menv/journal"
+)
+
+// useJournalFile records the runs of the test in a journal of its own.
+func useJournalFile(t *testing.T) {
+	t.Helper()
+	t.Setenv("MENV_JOURNAL", filepath.Join(t.TempDir(), "journal.jsonl"))
+	t.Cleanup(func() { recorder = nil })
+}
+
+// runRecorded runs one command against base, recorded in the journal.
+func runRecorded(t *testing.T, base env.Store, args ...string) {
+	t.Helper()
+	env.UseStore(base)
+	useJournal()
+	if err := execute(args); err != nil {
+		t.Fatalf("execute(%v) error = %v", args, err)
+	}
+	env.UseStore(base)
+}
+
+func TestHistoryAndRevert(t *testing.T) {
+	store := useStore(t)
+	useJournalFile(t)
+
+	runRecorded(t, store, "JAVA_HOME", `C:\jdk17`)
+	time.Sleep(10 * time.Millisecond)
+	between := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	runRecorded(t, store, "JAVA_HOME", `C:\jdk21`)
+
+	if err := showHistory(nil); err != nil {
+		t.Fatalf("showHistory() error = %v", err)
+	}
+	if err := showHistory([]string{"JAVA_HOME"}); err != nil {
+		t.Fatalf("showHistory(JAVA_HOME) error = %v", err)
+	}
+	if err := showHistory([]string{"OTHER"}); err != nil {
+		t.Fatalf("showHistory(OTHER) error = %v", err)
+	}
+	if err := showHistory([]string{"A", "B"}); err == nil {
+		t.Error("showHistory(A, B) error = nil, want an error")
+	}
+
+	setFlag(t, cmd.AsOf, between.Format(time.RFC3339Nano))
+	if err := showHistory(nil); err == nil {
+		t.Error("showHistory() with -as-of and no key error = nil, want an error")
+	}
+	if err := showHistory([]string{"JAVA_HOME"}); err != nil {
+		t.Fatalf("showHistory(-as-of, JAVA_HOME) error = %v", err)
+	}
+
+	// The revert asks first.
+	useStdin(t, "n\n")
+	if err := revertValue("JAVA_HOME", nil); err != nil {
+		t.Fatalf("revertValue() error = %v", err)
+	}
+	if got := userValue(t, store, "JAVA_HOME"); got != `C:\jdk21` {
+		t.Errorf("JAVA_HOME = %q after a cancelled revert, want C:\\jdk21", got)
+	}
+	setFlag(t, cmd.Yes, true)
+	if err := revertValue("JAVA_HOME", nil); err != nil {
+		t.Fatalf("revertValue() error = %v", err)
+	}
+	if got := userValue(t, store, "JAVA_HOME"); got != `C:\jdk17` {
+		t.Errorf("JAVA_HOME = %q after the revert, want C:\\jdk17", got)
+	}
+	if err := revertValue("JAVA_HOME", nil); err != nil {
+		t.Fatalf("revertValue() again error = %v", err)
+	}
+	if err := revertValue("OTHER", nil); err == nil {
+		t.Error("revertValue() without history error = nil, want an error")
+	}
+
+	setFlag(t, cmd.AsOf, "")
+	if err := revertValue("JAVA_HOME", nil); err == nil {
+		t.Error("revertValue() without -as-of error = nil, want an error")
+	}
+}
+
+func TestRevertValue_Unset(t *testing.T) {
+	store := useStore(t)
+	useJournalFile(t)
+	before := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	runRecorded(t, store, "A", "1")
+
+	setFlag(t, cmd.AsOf, before.Format(time.RFC3339Nano))
+	setFlag(t, cmd.Yes, true)
+	if err := showHistory([]string{"A"}); err != nil {
+		t.Fatalf("showHistory(A) error = %v", err)
+	}
+	if err := revertValue("A", nil); err != nil {
+		t.Fatalf("revertValue() error = %v", err)
+	}
+	if got := userValue(t, store, "A"); got != "<unset>" {
+		t.Errorf("A = %q, want it unset as it was before it was set", got)
+	}
+}
+
+// editingStore sets edit right after the first Get of its key, like another
+// process writing while menv asks for confirmation.
+type editingStore struct {
+	env.Store
+	edit env.EnvVar
+	done bool
+}
+
+func (s *editingStore) Get(scope env.Scope, key string) (env.EnvVar, bool, error) {
+	v, ok, err := s.Store.Get(scope, key)
+	if !s.done && key == s.edit.Key {
+		s.done = true
+		_ = s.Store.Set(scope, s.edit)
+	}
+	return v, ok, err
+}
+
+func TestRevertValue_ConflictAfterPreview(t *testing.T) {
+	store := useStore(t)
+	useJournalFile(t)
+	runRecorded(t, store, "A", "1")
+	time.Sleep(10 * time.Millisecond)
+	between := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	runRecorded(t, store, "A", "2")
+
+	env.UseStore(&editingStore{Store: store, edit: env.EnvVar{Key: "A", Value: "edited", Type: env.TypeString}})
+	setFlag(t, cmd.AsOf, between.Format(time.RFC3339Nano))
+	setFlag(t, cmd.Yes, true)
+	if err := revertValue("A", nil); !errors.Is(err, env.ErrConflict) {
+		t.Fatalf("revertValue() error = %v, want ErrConflict", err)
+	}
+	if got := userValue(t, store, "A"); got != "edited" {
+		t.Errorf("A = %q, want the change made after the preview kept", got)
+	}
+}
+
+func TestUndoChanges(t *testing.T) {
+	store := useStore(t)
+	useJournalFile(t)
+	runRecorded(t, store, "A", "1")
+	runRecorded(t, store, "A", "2")
+	runRecorded(t, store, "B", "3")
+
+	env.UseStore(store)
+	useJournal()
+	if err := undoChanges([]string{"2"}); err != nil {
+		t.Fatalf("undoChanges(2) error = %v", err)
+	}
+	env.UseStore(store)
+	if a, b := userValue(t, store, "A"), userValue(t, store, "B"); a != "1" || b != "<unset>" {
+		t.Errorf("A, B = %q, %q after undoing 2 changes, want 1, <unset>", a, b)
+	}
+	if err := showHistory(nil); err != nil {
+		t.Fatalf("showHistory() error = %v", err)
+	}
+
+	if err := undoChanges([]string{"x"}); err == nil {
+		t.Error("undoChanges(x) error = nil, want an error")
+	}
+	if err := undoChanges([]string{"1", "2"}); err == nil {
+		t.Error("undoChanges(1, 2) error = nil, want an error")
+	}
+	recorder = nil
+	if err := undoChanges(nil); err == nil {
+		t.Error("undoChanges() without a journal error = nil, want an error")
+	}
+}
+
+func TestShowHistory_ListEntries(t *testing.T) {
+	store := useStore(t, env.EnvVar{Key: "Path", Value: `C:\a;C:\b`, Type: env.TypeExpandString})
+	useJournalFile(t)
+	runRecorded(t, store, "Path", `C:\b;C:\a`)
+	runRecorded(t, store, "Path", `C:\b;C:\c`)
+
+	if err := showHistory([]string{"Path"}); err != nil {
+		t.Fatalf("showHistory(Path) error = %v", err)
+	}
+}
+
+func TestEntryChanges(t *testing.T) {
+	old := &env.EnvVar{Key: "Path", Value: `C:\a;C:\b`}
+	tests := []struct {
+		name string
+		new  *env.EnvVar
+		want []string
+	}{
+		{name: "added and removed", new: &env.EnvVar{Key: "Path", Value: `C:\b;C:\c`}, want: []string{`+ C:\c`, `- C:\a`}},
+		{name: "reordered", new: &env.EnvVar{Key: "Path", Value: `C:\b;C:\a`}, want: []string{"~ reordered"}},
+		{name: "deleted", want: []string{`- C:\a`, `- C:\b`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := entryChanges(journal.Entry{Key: "Path", Old: old, New: tt.new}, ";")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("entryChanges() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Package jsonl reads and appends files holding one JSON value per line,
+// such as the journal and the audit log.
+package jsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxLine is the longest line Scan reads.
+const maxLine = 1 << 20
+
+// Append adds v as a line to the end of the file at path, creating the
+// file and its directory if needed. The line is written with one call, so
+// that appends of concurrent processes do not interleave.
+func Append(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Scan decodes the lines of the file at path in order, calling fn with
+// each value. Blank lines are skipped and a missing file has no lines.
+func Scan[T any](path string, fn func(v T)) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxLine)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var v T
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		fn(v)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,60 @@
+package jsonl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	N    int    `json:"n"`
+	Text string `json:"text,omitempty"`
+}
+
+func scanAll(t *testing.T, path string) ([]item, error) {
+	t.Helper()
+	var items []item
+	err := Scan(path, func(v item) { items = append(items, v) })
+	return items, err
+}
+
+func TestAppendAndScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "log.jsonl")
+
+	if items, err := scanAll(t, path); err != nil || len(items) != 0 {
+		t.Fatalf("Scan() of a missing file = %v, %v, want nothing", items, err)
+	}
+
+	want := []item{{N: 1}, {N: 2, Text: "line\nbreak"}}
+	for _, v := range want {
+		if err := Append(path, v); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	got, err := scanAll(t, path)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Scan() = %v, want %v", got, want)
+	}
+}
+
+func TestScan_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	if err := os.WriteFile(path, []byte("{\"n\":1}\n\n  \n{bad}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scanAll(t, path); err == nil || !strings.Contains(err.Error(), "log.jsonl:4:") {
+		t.Errorf("Scan() error = %v, want one naming line 4", err)
+	}
+
+	if err := Append(path, func() {}); err == nil {
+		t.Error("Append() of a value that is not JSON succeeded")
+	}
+	if err := Append(filepath.Join(path, "sub", "x.jsonl"), item{}); err == nil {
+		t.Error("Append() below a regular file succeeded")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/env"
+	"github.com/doraemonkeys/menv/lock"
+)
+
+// lockTimeout is how long menv waits for another menv to finish a write.
+const lockTimeout = time.Minute
+
+// lockScope takes the lock that serializes parallel runs of menv changing
+// the variables of scope: per user for user variables, machine-wide for
+// system ones. It is held only while a write compares and writes, never
+// while a prompt waits.
+func lockScope(scope env.Scope) (release func(), err error) {
+	filename, err := lock.DefaultPath()
+	if scope == env.ScopeSystem {
+		filename, err = lock.SystemPath()
+	}
+	if err != nil {
+		color.Warning("writing without a lock: %v", err)
+		return func() {}, nil
+	}
+	l, err := lock.Acquire(filename, lockTimeout, func() {
+		color.Info("Waiting for another menv to finish...")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = l.Release() }, nil
+}
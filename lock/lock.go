@@ -0,0 +1,81 @@
+// Package lock provides an inter-process lock on a file, so that parallel
+// runs of menv do not interleave their read-modify-write cycles.
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrTimeout is returned when the lock is still held by another process
+// after the wait.
+var ErrTimeout = errors.New("timed out waiting for the lock")
+
+// pollInterval is how often a held lock is tried again.
+const pollInterval = 50 * time.Millisecond
+
+// Lock is a held lock file.
+type Lock struct {
+	f *os.File
+}
+
+// lockFile is the name of the lock files.
+const lockFile = "menv.lock"
+
+// DefaultPath returns menv.lock in the menv directory of the user's config
+// directory, which serializes changes to the user's variables.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "menv", lockFile), nil
+}
+
+// Acquire takes the lock on the file at path, creating it if needed. If
+// another process holds it, waiting is called once and the lock is tried
+// again until timeout. The file is opened read-only, so that a machine-wide
+// lock file created by one user can be locked by the others.
+func Acquire(path string, timeout time.Duration, waiting func()) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("cannot lock %s: %w", path, err)
+		}
+		if ok {
+			return &Lock{f: f}, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", path, ErrTimeout)
+		}
+		if waiting != nil {
+			waiting()
+			waiting = nil
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Release releases the lock. The lock file is left in place, as removing
+// it could let two processes lock different files.
+func (l *Lock) Release() error {
+	err := unlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
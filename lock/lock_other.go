@@ -0,0 +1,39 @@
+//go:build !windows
+
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// systemLockDir is the directory for lock files shared by all users.
+const systemLockDir = "/run/lock"
+
+// SystemPath returns the machine-wide menv.lock, which serializes changes
+// to system variables across users: in /run/lock, or /tmp where there is
+// no /run/lock.
+func SystemPath() (string, error) {
+	dir := systemLockDir
+	if _, err := os.Stat(dir); err != nil {
+		dir = "/tmp"
+	}
+	return filepath.Join(dir, lockFile), nil
+}
+
+// tryLock takes an exclusive flock on f without waiting; ok is false if
+// another process holds it.
+func tryLock(f *os.File) (ok bool, err error) {
+	err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,52 @@
+package lock
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "menv", "menv.lock")
+
+	l, err := Acquire(path, time.Second, nil)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	waited := 0
+	if _, err := Acquire(path, 100*time.Millisecond, func() { waited++ }); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Acquire() of held lock error = %v, want ErrTimeout", err)
+	}
+	if waited != 1 {
+		t.Errorf("waiting called %d times, want 1", waited)
+	}
+
+	// The lock is taken as soon as it is released.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = l.Release()
+	}()
+	l2, err := Acquire(path, 5*time.Second, nil)
+	if err != nil {
+		t.Fatalf("Acquire() after Release() error = %v", err)
+	}
+	if err := l2.Release(); err != nil {
+		t.Errorf("Release() error = %v", err)
+	}
+}
+
+func TestSystemPath(t *testing.T) {
+	path, err := SystemPath()
+	if err != nil {
+		t.Skipf("no system lock path: %v", err)
+	}
+	user, err := DefaultPath()
+	if err != nil {
+		t.Skipf("no user lock path: %v", err)
+	}
+	if path == user || filepath.Base(path) != "menv.lock" {
+		t.Errorf("SystemPath() = %q, want a menv.lock shared by all users, not %q", path, user)
+	}
+}
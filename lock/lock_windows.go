@@ -0,0 +1,36 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// SystemPath returns the machine-wide menv.lock in %ProgramData%\menv,
+// which serializes changes to system variables across users.
+func SystemPath() (string, error) {
+	dir := os.Getenv("ProgramData")
+	if dir == "" {
+		return "", errors.New("%ProgramData% is not defined")
+	}
+	return filepath.Join(dir, "menv", lockFile), nil
+}
+
+// tryLock locks the first byte of f exclusively without waiting; ok is
+// false if another process holds it.
+func tryLock(f *os.File) (ok bool, err error) {
+	var ol windows.Overlapped
+	err = windows.LockFileEx(windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(f *os.File) error {
+	var ol windows.Overlapped
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestLockScope(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("AppData", dir)
+	store := useStore(t)
+	env.UseLocker(lockScope)
+	t.Cleanup(func() { env.UseLocker(nil) })
+
+	release, err := lockScope(env.ScopeUser)
+	if err != nil {
+		t.Fatalf("lockScope() error = %v", err)
+	}
+	release()
+
+	if err := execute([]string{"A", "1"}); err != nil {
+		t.Fatalf("execute() under the lock error = %v", err)
+	}
+	if got := userValue(t, store, "A"); got != "1" {
+		t.Errorf("A = %q, want 1", got)
+	}
+}
@@ -26,20 +26,63 @@ func init() {
 		fmt.Println("  -get <key>        Get env var value")
 		fmt.Println("  -path             Display PATH (one per line)")
 		fmt.Println("  -add <path>       Add path to PATH variable")
+		fmt.Println("  -front            With -add, insert at the front")
+		fmt.Println("  -at <n>           With -add, insert as entry n (1-based)")
+		fmt.Println("  -before <entry>   With -add, insert before an existing entry")
+		fmt.Println("  -after <entry>    With -add, insert after an existing entry")
+		fmt.Println("  -move             With -add, move an existing entry instead of skipping it")
 		fmt.Println("  -rm <path>        Remove path from PATH variable")
+		fmt.Println("  -mv <entry|n> <m> Move a PATH entry (path or index) to position m")
+		fmt.Println("  -up, -down        With -mv, move the entry one place up or down")
 		fmt.Println("  -clean            Clean PATH (dedupe + remove invalid)")
 		fmt.Println("  -check            Check PATH for invalid directories")
+		fmt.Println("  -var <name>       Use -add/-rm/-clean/-check/-path on another list variable")
+		fmt.Println("                    e.g. PSModulePath, CLASSPATH, PYTHONPATH")
+		fmt.Println("  -sep <sep>        Entry separator of -var (default: , for NO_PROXY and GOPROXY,")
+		fmt.Println("                    ; on Windows, : elsewhere)")
 		fmt.Println("  -fix              Auto-remove invalid paths (use with -check)")
 		fmt.Println("  -y                Skip confirmation prompts")
 		fmt.Println("  -d                Delete environment variable")
+		fmt.Println("  -cascade          With -d, also remove vars and PATH entries referencing it")
+		fmt.Println("  -inline           With -d, replace references to it with its value")
+		fmt.Println("  -rename <old> <new>  Rename env var, keeping its value and type")
+		fmt.Println("  -force            With -rename/-promote/-demote, overwrite an existing target;")
+		fmt.Println("                    with -undo, revert vars changed since")
+		fmt.Println("  -refs             With -rename, rewrite %OLD% references in other vars")
+		fmt.Println("  -promote <key>    Move user env var (or PATH entry with -path) to system")
+		fmt.Println("  -demote <key>     Move system env var (or PATH entry with -path) to user")
+		fmt.Println("  -keep             With -promote/-demote, copy instead of move")
 		fmt.Println("  -sys              Target system env (default: user)")
+		fmt.Println("  -history          List recorded changes (newest first)")
+		fmt.Println("  -history <key>    Show the values of a var over time (PATH: per entry)")
+		fmt.Println("  -as-of <time>     With -history <key>, print the value at a time")
+		fmt.Println("                    (2006-01-02 15:04, 7d, 12h)")
+		fmt.Println("  -revert <key>     Restore a var to its value at the -as-of time")
+		fmt.Println("  -undo [n]         Undo the last n recorded changes (default 1)")
+		fmt.Println("  -audit [key]      List the audit log, set MENV_AUDIT_LOG=<file> to turn it on")
+		fmt.Println("  -scope <scope>    With -audit, only user or system changes")
+		fmt.Println("  -since, -until <time>  With -audit, only changes in this time range")
+		fmt.Println("                    (a date-only -until includes the whole day)")
+		fmt.Println("  -n, -dry-run      Print the changes a command would make, write nothing")
+		fmt.Println("  -check-only       Like -dry-run, but fail if there would be changes")
+		fmt.Println("  -effective        Show merged system+user env (with -list, -get, -path)")
+		fmt.Println("  -expand           Also print the value with references expanded (with -get)")
+		fmt.Println("  -graph            Show variable reference graph, dangling refs and cycles")
+		fmt.Println("  -dot              Print -graph as Graphviz DOT")
 		fmt.Println("  -file <path>      Read env vars from file")
 		fmt.Println("  -startWith <str>  Filter lines starting with string")
 		fmt.Println("  -export <path>    Export env vars to file (sh/bat/json)")
+		fmt.Println("  -typed            With -export to .json, keep value types as")
+		fmt.Println("                    {\"KEY\": {\"value\": ..., \"type\": ...}}")
 		fmt.Println("  -backup <path>    Backup env vars to JSON file")
 		fmt.Println("  -restore <path>   Restore env vars from backup file")
+		fmt.Println("  -mode <mode>      With -restore: merge (default), replace (delete vars not")
+		fmt.Println("                    in the backup) or missing (only create unset vars)")
 		fmt.Println("  -search <keyword> Search env vars by keyword")
 		fmt.Println("                    Use with -path to search in PATH")
+		fmt.Println("  -store <spec>     Env store: registry, profile, environment.d,")
+		fmt.Println("                    memory, file:<path>")
+		fmt.Println("                    (default: registry on Windows, profile elsewhere)")
 		fmt.Println()
 		color.Info("Examples:")
 		fmt.Println("  menv -list                         # List user env vars")
@@ -47,32 +90,70 @@ func init() {
 		fmt.Println("  menv -get JAVA_HOME                # Get JAVA_HOME value")
 		fmt.Println("  menv -path                         # Display user PATH")
 		fmt.Println("  menv -path -sys                    # Display system PATH")
+		fmt.Println("  menv -path -effective              # Display system+user PATH with sources")
+		fmt.Println("  menv -list -effective              # List merged env, flag shadowed vars")
+		fmt.Println("  menv -get JAVA_HOME -expand        # Show JAVA_HOME with %VAR% expanded")
+		fmt.Println("  menv -graph                        # Show which vars reference which")
+		fmt.Println("  menv -graph -dot > env.dot         # Export reference graph for Graphviz")
 		fmt.Println("  menv GOPATH C:\\Go                  # Set user env var")
 		fmt.Println("  menv -sys GOPATH C:\\Go             # Set system env var")
 		fmt.Println("  menv -d GOPATH                     # Delete user env var")
 		fmt.Println("  menv -d -sys GOPATH                # Delete system env var")
+		fmt.Println("  menv -d -cascade JAVA_HOME         # Delete with vars/PATH entries using it")
+		fmt.Println("  menv -d -inline JAVA_HOME          # Delete, writing its value into dependents")
+		fmt.Println("  menv -rename JAVA_HOME_17 JAVA_HOME   # Rename, keeping REG_EXPAND_SZ")
+		fmt.Println("  menv -refs -rename JDK JAVA_HOME   # Rename and update %JDK% references")
+		fmt.Println("  menv -promote GOROOT               # Move GOROOT from user to system")
+		fmt.Println("  menv -force -promote GOROOT        # ... overwriting a different system value")
+		fmt.Println("  menv -path -demote C:\\tools       # Move a PATH entry from system to user")
+		fmt.Println("  menv -keep -promote GOROOT         # Copy GOROOT to system, keep user value")
 		fmt.Println("  menv -add \"C:\\bin\"                 # Add to user PATH")
 		fmt.Println("  menv -add \"C:\\bin\" -sys            # Add to system PATH")
+		fmt.Println("  menv -add C:\\Python313 -front      # Put Python ahead of the Store stub")
+		fmt.Println("  menv -add C:\\bin -at 2             # Insert as the second PATH entry")
+		fmt.Println("  menv -add C:\\new -before C:\\old    # Insert before another entry")
+		fmt.Println("  menv -add C:\\bin -front -move      # Move an existing entry to the front")
 		fmt.Println("  menv -rm \"C:\\bin\"                  # Remove from user PATH")
 		fmt.Println("  menv -rm \"C:\\bin\" -sys             # Remove from system PATH")
+		fmt.Println("  menv -mv 5 1                       # Make the 5th PATH entry the first")
+		fmt.Println("  menv -mv C:\\Python313 -up          # Move an entry one place up")
 		fmt.Println("  menv -clean                        # Clean user PATH")
 		fmt.Println("  menv -clean -sys                   # Clean system PATH")
 		fmt.Println("  menv -clean -y                     # Clean without confirmation")
+		fmt.Println("  menv -n -add C:\\bin -front         # Preview the PATH after adding")
+		fmt.Println("  menv -n -restore backup.json       # Preview what a restore would change")
+		fmt.Println("  menv -check-only -file env.sh      # Fail if env.sh is not applied yet")
+		fmt.Println("  menv -history                      # Show what menv changed, and when")
+		fmt.Println("  menv -history JAVA_HOME            # Show how JAVA_HOME changed")
+		fmt.Println("  menv -history -sys PATH            # Show when system PATH entries came and went")
+		fmt.Println("  menv -history -as-of 7d JAVA_HOME  # Print JAVA_HOME as of a week ago")
+		fmt.Println("  menv -revert JAVA_HOME -as-of \"2026-10-01 09:00\"  # Restore it as of then")
+		fmt.Println("  menv -undo                         # Revert the last change")
+		fmt.Println("  menv -undo 3                       # Revert the last 3 changes")
+		fmt.Println("  menv -audit -scope system -since 7d   # Who changed system vars this week")
+		fmt.Println("  menv -audit -until 2026-10-08 JAVA_HOME  # JAVA_HOME changes up to Oct 8, inclusive")
 		fmt.Println("  menv -file env.sh -startWith export  # Set env vars from file")
 		fmt.Println("  menv -export env.sh                # Export user env as shell")
 		fmt.Println("  menv -export env.bat               # Export user env as batch")
 		fmt.Println("  menv -export env.json              # Export user env as JSON")
 		fmt.Println("  menv -export env.json -sys         # Export system env as JSON")
+		fmt.Println("  menv -export env.json -typed       # Export JSON with value types")
 		fmt.Println("  menv -backup backup.json           # Backup user env vars")
 		fmt.Println("  menv -backup backup.json -sys      # Backup system env vars")
 		fmt.Println("  menv -restore backup.json          # Restore user env vars")
 		fmt.Println("  menv -restore backup.json -sys     # Restore system env vars")
+		fmt.Println("  menv -restore backup.json -mode replace  # Make user env vars match the backup")
+		fmt.Println("  menv -restore backup.json -mode missing  # Only create vars that are not set")
 		fmt.Println("  menv -search java                  # Search env vars for 'java'")
 		fmt.Println("  menv -search java -path            # Search PATH for 'java'")
 		fmt.Println("  menv -check                        # Check user PATH for invalid dirs")
 		fmt.Println("  menv -check -sys                   # Check system PATH for invalid dirs")
 		fmt.Println("  menv -check -fix                   # Check and remove invalid paths")
 		fmt.Println("  menv -check -fix -y                # Check and remove without confirmation")
+		fmt.Println("  menv -list -store file:env.json    # List user env vars from a JSON file")
+		fmt.Println("  menv -add ~/bin -store environment.d  # Add to PATH in environment.d")
+		fmt.Println("  menv -add C:\\mods -var PSModulePath  # Add to user PSModulePath")
+		fmt.Println("  menv -clean -var CLASSPATH         # Clean user CLASSPATH")
 	}
 }
 
@@ -87,6 +168,42 @@ func main() {
 }
 
 func run(args []string) error {
+	if *cmd.StoreSpec != "" {
+		store, err := env.OpenStore(*cmd.StoreSpec)
+		if err != nil {
+			return err
+		}
+		env.UseStore(store)
+	}
+	if *cmd.ListVar != "" || *cmd.Separator != "" {
+		path.UseVar(path.ListVar{Key: *cmd.ListVar, Separator: *cmd.Separator})
+	}
+	useJournal()
+	useAudit()
+	if *cmd.DryRun || *cmd.CheckOnly {
+		return dryRun(args)
+	}
+	env.UseLocker(lockScope)
+	return execute(args)
+}
+
+// execute runs the command selected by the flags against the current store.
+func execute(args []string) error {
+	// Handle -history flag: list the changes recorded in the journal
+	if *cmd.History {
+		return showHistory(args)
+	}
+
+	// Handle -audit flag: query the audit log
+	if *cmd.Audit {
+		return showAudit(args)
+	}
+
+	// Handle -undo flag: revert the last recorded changes
+	if *cmd.Undo {
+		return undoChanges(args)
+	}
+
 	// Handle -list flag: list all env vars
 	if *cmd.ListEnv {
 		return listEnvVars()
@@ -97,6 +214,11 @@ func run(args []string) error {
 		return getEnvVar(*cmd.GetEnv)
 	}
 
+	// Handle -promote/-demote flags: move env var or PATH entry between scopes
+	if *cmd.Promote != "" || *cmd.Demote != "" {
+		return transferScope(args)
+	}
+
 	// Handle -search flag: search env vars or PATH
 	if *cmd.Search != "" {
 		if *cmd.ShowPath {
@@ -105,6 +227,11 @@ func run(args []string) error {
 		return searchEnvVars(*cmd.Search)
 	}
 
+	// Handle -graph flag: display variable reference graph
+	if *cmd.Graph {
+		return showGraph()
+	}
+
 	// Handle -path flag: display PATH
 	if *cmd.ShowPath {
 		return showPath()
@@ -125,6 +252,22 @@ func run(args []string) error {
 		return restoreEnvVars(*cmd.RestorePath)
 	}
 
+	// Handle -rename flag: rename env var within its scope
+	if *cmd.Rename != "" {
+		if len(args) != 1 {
+			return fmt.Errorf("usage: menv -rename OLD NEW")
+		}
+		return env.Rename(env.ScopeOf(*cmd.SetSystem), *cmd.Rename, args[0], *cmd.Force, *cmd.RewriteRefs)
+	}
+
+	// Handle -revert flag: restore a var to its value at the -as-of time
+	if *cmd.Revert != "" {
+		return revertValue(*cmd.Revert, args)
+	}
+	if *cmd.At != "" && *cmd.AddPath == "" {
+		return fmt.Errorf("-at is only valid with -add, use -as-of for a point in time")
+	}
+
 	// Handle PATH modification commands
 	if handled, err := handlePathCommands(args); handled {
 		return err
@@ -155,7 +298,11 @@ func handlePathCommands(args []string) (handled bool, err error) {
 		if len(args) != 0 {
 			return true, fmt.Errorf("unexpected arguments: %v", args)
 		}
-		return true, path.Add(*cmd.AddPath, *cmd.SetSystem)
+		pos, err := addPosition()
+		if err != nil {
+			return true, err
+		}
+		return true, path.AddAt(*cmd.AddPath, pos, *cmd.SetSystem)
 	}
 
 	if *cmd.RemovePath != "" {
@@ -165,6 +312,10 @@ func handlePathCommands(args []string) (handled bool, err error) {
 		return true, path.Remove(*cmd.RemovePath, *cmd.SetSystem)
 	}
 
+	if *cmd.MovePath != "" {
+		return true, movePath(args)
+	}
+
 	if *cmd.CleanPath {
 		if len(args) != 0 {
 			return true, fmt.Errorf("unexpected arguments: %v", args)
@@ -182,13 +333,6 @@ func handlePathCommands(args []string) (handled bool, err error) {
 	return false, nil
 }
 
-func deleteEnvVar(key string) error {
-	if *cmd.SetSystem {
-		return env.UnsetSystem(key)
-	}
-	return env.Unset(key)
-}
-
 func setEnvVar(args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("missing value for key '%s'", args[0])
@@ -210,29 +354,30 @@ func processEnvFile() error {
 		return err
 	}
 
+	// The file is applied as one changeset, so a failed write rolls back
+	// the ones before it.
+	scope := env.ScopeOf(*cmd.SetSystem)
+	var c env.Changeset
 	for _, v := range envMap {
-		if err := applyEnvVar(v.First, v.Second); err != nil {
-			return err
+		if *cmd.DelEnv {
+			c.Delete(scope, v.First)
+		} else {
+			c.Set(scope, env.EnvVar{Key: v.First, Value: v.Second})
 		}
 	}
-
+	summary, err := env.ApplyChanges(&c)
+	if err != nil {
+		return err
+	}
+	color.Info("%s", summary)
 	return nil
 }
 
-func applyEnvVar(key, value string) error {
-	if *cmd.DelEnv {
-		if *cmd.SetSystem {
-			return env.UnsetSystem(key)
-		}
-		return env.Unset(key)
-	}
-	if *cmd.SetSystem {
-		return env.SetSystem(key, value)
+func listEnvVars() error {
+	if *cmd.Effective {
+		return listEffective()
 	}
-	return env.Set(key, value)
-}
 
-func listEnvVars() error {
 	var envVars []env.EnvVar
 	var err error
 
@@ -250,13 +395,26 @@ func listEnvVars() error {
 
 	fmt.Println()
 	for _, e := range envVars {
-		fmt.Printf("%s%s%s=%s\n", color.Green, e.Key, color.Reset, e.Value)
+		printEnvVar(e)
 	}
 	fmt.Printf("\nTotal: %d\n", len(envVars))
 	return nil
 }
 
+// printEnvVar prints KEY=value followed by the value type, if known.
+func printEnvVar(e env.EnvVar) {
+	fmt.Printf("%s%s%s=%s", color.Green, e.Key, color.Reset, e.Value)
+	if e.Type != "" {
+		fmt.Printf("  %s[%s]%s", color.Blue, e.Type, color.Reset)
+	}
+	fmt.Println()
+}
+
 func getEnvVar(key string) error {
+	if *cmd.Effective {
+		return getEffective(key)
+	}
+
 	var value string
 	var err error
 
@@ -276,18 +434,25 @@ func getEnvVar(key string) error {
 	}
 
 	fmt.Printf("%s%s%s=%s\n", color.Green, key, color.Reset, value)
+	if *cmd.Expand {
+		return printExpansion(key, value, env.ScopeOf(*cmd.SetSystem))
+	}
 	return nil
 }
 
 func showPath() error {
+	if *cmd.Effective {
+		return showEffectivePath()
+	}
+
 	var paths []string
 	var err error
 
 	if *cmd.SetSystem {
-		color.Info("System PATH:")
+		color.Info("System %s:", path.Name())
 		paths, err = path.QuerySystemPath()
 	} else {
-		color.Info("User PATH:")
+		color.Info("User %s:", path.Name())
 		paths, err = path.QueryUserPath()
 	}
 
@@ -317,11 +482,17 @@ func exportEnvVars(filename string) error {
 		return err
 	}
 
-	if err := env.Export(filename, envVars); err != nil {
+	format := env.DetectFormat(filename)
+	if *cmd.Typed {
+		if format != env.FormatJSON {
+			return fmt.Errorf("-typed needs a .json export file, got %s", filename)
+		}
+		format = env.FormatTypedJSON
+	}
+	if err := env.ExportAs(filename, format, envVars); err != nil {
 		return err
 	}
 
-	format := env.DetectFormat(filename)
 	color.Success("Exported %d env vars to %s (format: %s)", len(envVars), filename, format)
 	return nil
 }
@@ -340,28 +511,6 @@ func backupEnvVars(filename string) error {
 	return nil
 }
 
-func restoreEnvVars(filename string) error {
-	backup, err := env.LoadBackup(filename)
-	if err != nil {
-		return err
-	}
-
-	target := "user"
-	if *cmd.SetSystem {
-		target = "system"
-	}
-	color.Info("Restoring %d env vars from %s backup (created: %s) to %s...",
-		len(backup.EnvVars), backup.Source, backup.CreatedAt.Format("2006-01-02 15:04:05"), target)
-
-	count, err := env.Restore(filename, *cmd.SetSystem)
-	if err != nil {
-		return err
-	}
-
-	color.Success("Restored %d env vars", count)
-	return nil
-}
-
 func searchEnvVars(keyword string) error {
 	var results []env.EnvVar
 	var err error
@@ -385,7 +534,7 @@ func searchEnvVars(keyword string) error {
 
 	fmt.Println()
 	for _, e := range results {
-		fmt.Printf("%s%s%s=%s\n", color.Green, e.Key, color.Reset, e.Value)
+		printEnvVar(e)
 	}
 	fmt.Printf("\nFound: %d\n", len(results))
 	return nil
@@ -396,10 +545,10 @@ func searchPath(keyword string) error {
 	var err error
 
 	if *cmd.SetSystem {
-		color.Info("Searching system PATH for '%s':", keyword)
+		color.Info("Searching system %s for '%s':", path.Name(), keyword)
 		results, err = path.SearchSystemPath(keyword)
 	} else {
-		color.Info("Searching user PATH for '%s':", keyword)
+		color.Info("Searching user %s for '%s':", path.Name(), keyword)
 		results, err = path.SearchUserPath(keyword)
 	}
 
@@ -426,7 +575,10 @@ func cleanPath() error {
 		scope = "system"
 	}
 
-	color.Info("Cleaning %s PATH...", scope)
+	color.Info("Cleaning %s %s...", scope, path.Name())
+	if !path.HoldsPaths() {
+		color.Info("%s does not hold paths, only duplicates are removed", path.Name())
+	}
 
 	result, err := path.PreviewClean(*cmd.SetSystem)
 	if err != nil {
@@ -434,7 +586,7 @@ func cleanPath() error {
 	}
 
 	if len(result.Duplicates) == 0 && len(result.Invalid) == 0 {
-		color.Success("PATH is clean, no changes needed")
+		color.Success("%s is clean, no changes needed", path.Name())
 		return nil
 	}
 
@@ -455,7 +607,7 @@ func cleanPath() error {
 		}
 	}
 
-	return path.ApplyClean(result.NewPath, *cmd.SetSystem)
+	return path.ApplyClean(result, *cmd.SetSystem)
 }
 
 func checkPath() error {
@@ -464,7 +616,7 @@ func checkPath() error {
 		scope = "system"
 	}
 
-	color.Info("Checking %s PATH for invalid directories...", scope)
+	color.Info("Checking %s %s for invalid directories...", scope, path.Name())
 
 	invalid, err := path.Check(*cmd.SetSystem)
 	if err != nil {
@@ -498,6 +650,10 @@ func checkPath() error {
 }
 
 func confirmAction(prompt string) bool {
+	// A dry run writes nothing, so there is nothing to confirm.
+	if *cmd.DryRun || *cmd.CheckOnly {
+		return true
+	}
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("%s [y/N]: ", prompt)
 	input, err := reader.ReadString('\n')
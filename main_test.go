@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+)
+
+// useStore runs the test against a memory store holding vars in the user
+// scope.
+func useStore(t *testing.T, vars ...env.EnvVar) *env.MemoryStore {
+	t.Helper()
+	store := env.NewMemoryStore()
+	for _, v := range vars {
+		if err := store.Set(env.ScopeUser, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prev := env.CurrentStore()
+	env.UseStore(store)
+	t.Cleanup(func() { env.UseStore(prev) })
+	return store
+}
+
+// setFlag sets a command-line flag for the rest of the test.
+func setFlag[T any](t *testing.T, flag *T, value T) {
+	t.Helper()
+	prev := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = prev })
+}
+
+// useStdin makes prompts read input.
+func useStdin(t *testing.T, input string) {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(filename, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = prev
+		f.Close()
+	})
+}
+
+// userValue returns the value of the user variable key, or "<unset>".
+func userValue(t *testing.T, store env.Store, key string) string {
+	t.Helper()
+	v, ok, err := store.Get(env.ScopeUser, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		return "<unset>"
+	}
+	return v.Value
+}
+
+func TestExecute_SetAndDelete(t *testing.T) {
+	store := useStore(t)
+
+	if err := execute([]string{"JAVA_HOME", `C:\jdk`}); err != nil {
+		t.Fatalf("execute(JAVA_HOME C:\\jdk) error = %v", err)
+	}
+	if got := userValue(t, store, "JAVA_HOME"); got != `C:\jdk` {
+		t.Errorf("JAVA_HOME = %q, want C:\\jdk", got)
+	}
+
+	setFlag(t, cmd.DelEnv, true)
+	if err := execute([]string{"JAVA_HOME"}); err != nil {
+		t.Fatalf("execute(-d JAVA_HOME) error = %v", err)
+	}
+	if got := userValue(t, store, "JAVA_HOME"); got != "<unset>" {
+		t.Errorf("JAVA_HOME = %q after -d, want it unset", got)
+	}
+}
@@ -2,115 +2,109 @@ package path
 
 import (
 	"errors"
+	"fmt"
 	"os"
-	"os/exec"
+	"slices"
 	"strings"
 
 	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/env"
 )
 
-// Add adds a path to the PATH environment variable.
+// Add adds a path to the end of the PATH environment variable, or the
+// variable selected with UseVar.
 // If sys is true, modifies system PATH; otherwise modifies user PATH.
-// Note: setx has a 1024 character limit, so PowerShell is used instead.
 func Add(add string, sys bool) error {
-	if strings.ContainsRune(add, ';') {
-		return errors.New("invalid path: " + add)
-	}
-
-	var (
-		paths []string
-		err   error
-	)
+	return AddAt(add, Position{}, sys)
+}
 
-	if sys {
-		paths, err = QuerySystemPath()
-	} else {
-		paths, err = QueryUserPath()
-	}
-	if err != nil {
+// AddAt adds a path to PATH at pos. A path that already exists is skipped
+// unless pos.Move is set, in which case it is moved to pos.
+func AddAt(add string, pos Position, sys bool) error {
+	if err := pos.validate(); err != nil {
 		return err
 	}
+	conv := Conventions()
+	if strings.Contains(add, conv.ListSeparator) {
+		return errors.New("invalid path: " + add)
+	}
 
 	// Normalize the path to add
 	add = normalizePath(add)
-
-	// Check if path already exists
-	var newPath string
-	for _, p := range paths {
-		p = normalizePath(p)
-		if p == add {
-			color.Warning("skip %s (already exists)", add)
-			return nil
+	addKey := entryKey(add, conv)
+
+	var i int
+	exists := false
+	err := updatePath(env.ScopeOf(sys), true, func(paths []string) ([]string, error) {
+		// Check if path already exists
+		newPaths := make([]string, 0, len(paths)+1)
+		exists = false
+		for _, p := range paths {
+			p = normalizePath(p)
+			if entryKey(p, conv) == addKey {
+				if !pos.Move {
+					return nil, errSkip
+				}
+				exists = true
+				continue
+			}
+			newPaths = append(newPaths, p)
 		}
-		newPath += p + ";"
-	}
 
-	newPath += add + ";"
-
-	// Use PowerShell to set the path (avoids setx 1024 char limit)
-	var cmd *exec.Cmd
-	scope := "user"
-	if sys {
-		scope = "system"
-		cmd = exec.Command("powershell", "[System.Environment]::SetEnvironmentVariable(\"Path\", \""+newPath+"\", \"Machine\")")
-	} else {
-		cmd = exec.Command("powershell", "[System.Environment]::SetEnvironmentVariable(\"Path\", \""+newPath+"\", \"User\")")
+		var err error
+		if i, err = pos.index(newPaths); err != nil {
+			return nil, err
+		}
+		return insertAt(newPaths, i, add), nil
+	})
+	if errors.Is(err, errSkip) {
+		color.Warning("skip %s (already exists)", add)
+		return nil
 	}
-
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		return err
 	}
-	color.Success("add  %s [%s PATH]", add, scope)
+	verb := "add "
+	if exists {
+		verb = "move"
+	}
+	if pos == (Position{}) {
+		color.Done("%s %s [%s %s]", verb, add, env.ScopeOf(sys), Name())
+	} else {
+		color.Done("%s %s [%s %s] at %d", verb, add, env.ScopeOf(sys), Name(), i+1)
+	}
 	return nil
 }
 
 // Remove removes a path from the PATH environment variable.
 func Remove(remove string, sys bool) error {
-	var (
-		paths []string
-		err   error
-	)
-
-	if sys {
-		paths, err = QuerySystemPath()
-	} else {
-		paths, err = QueryUserPath()
-	}
-	if err != nil {
-		return err
-	}
-
+	conv := Conventions()
 	remove = normalizePath(remove)
-	removeNorm := strings.ToLower(remove)
-
-	var newPath string
-	found := false
-	for _, p := range paths {
-		pNorm := strings.ToLower(normalizePath(p))
-		if pNorm == removeNorm {
-			found = true
-			continue
+	removeNorm := entryKey(remove, conv)
+
+	err := updatePath(env.ScopeOf(sys), true, func(paths []string) ([]string, error) {
+		var newPaths []string
+		found := false
+		for _, p := range paths {
+			if entryKey(p, conv) == removeNorm {
+				found = true
+				continue
+			}
+			newPaths = append(newPaths, p)
 		}
-		newPath += p + ";"
-	}
-
-	if !found {
+		if !found {
+			return nil, errSkip
+		}
+		return newPaths, nil
+	})
+	if errors.Is(err, errSkip) {
 		color.Warning("path not found: %s", remove)
 		return nil
 	}
-
-	scope := "user"
-	target := "User"
-	if sys {
-		scope = "system"
-		target = "Machine"
-	}
-
-	cmd := exec.Command("powershell", "[System.Environment]::SetEnvironmentVariable(\"Path\", \""+newPath+"\", \""+target+"\")")
-	if err := cmd.Run(); err != nil {
+	if err != nil {
 		return err
 	}
-	color.Success("removed %s [%s PATH]", remove, scope)
+	color.Done("removed %s [%s %s]", remove, env.ScopeOf(sys), Name())
 	return nil
 }
 
@@ -119,29 +113,31 @@ type CleanResult struct {
 	Duplicates []string
 	Invalid    []string
 	NewPath    string
+	// Paths are the entries that were analyzed.
+	Paths []string
 }
 
-// PreviewClean analyzes PATH and returns what would be cleaned.
+// PreviewClean analyzes PATH and returns what would be cleaned. Entries
+// of a variable that does not hold paths are only deduplicated.
 func PreviewClean(sys bool) (CleanResult, error) {
-	var (
-		paths []string
-		err   error
-	)
-
-	if sys {
-		paths, err = QuerySystemPath()
-	} else {
-		paths, err = QueryUserPath()
+	paths, err := queryPath(env.ScopeOf(sys))
+	if err != nil {
+		return CleanResult{}, err
 	}
+
+	x, err := env.NewExpander(env.ScopeOf(sys))
 	if err != nil {
 		return CleanResult{}, err
 	}
 
+	conv := Conventions()
+	checkExists := HoldsPaths()
 	seen := make(map[string]bool, len(paths))
 	var result CleanResult
+	var kept []string
 
 	for _, p := range paths {
-		pNorm := strings.ToLower(normalizePath(p))
+		pNorm := entryKey(p, conv)
 
 		if seen[pNorm] {
 			result.Duplicates = append(result.Duplicates, p)
@@ -149,65 +145,147 @@ func PreviewClean(sys bool) (CleanResult, error) {
 		}
 		seen[pNorm] = true
 
-		if !pathExists(p) {
+		if checkExists && !pathExists(p, x) {
 			result.Invalid = append(result.Invalid, p)
 			continue
 		}
 
-		result.NewPath += p + ";"
+		kept = append(kept, p)
 	}
 
+	result.NewPath = joinPath(kept, conv)
+	result.Paths = paths
 	return result, nil
 }
 
-// ApplyClean applies the cleaned PATH to the registry.
-func ApplyClean(newPath string, sys bool) error {
-	scope := "user"
-	target := "User"
-	if sys {
-		scope = "system"
-		target = "Machine"
+// ApplyClean removes the duplicates and invalid paths found by PreviewClean.
+// If PATH has changed since, the same entries are removed from the current
+// value, which is written only if it has not changed again meanwhile.
+func ApplyClean(result CleanResult, sys bool) error {
+	conv := Conventions()
+	invalid := make(map[string]bool, len(result.Invalid))
+	for _, p := range result.Invalid {
+		invalid[entryKey(p, conv)] = true
 	}
 
-	cmd := exec.Command("powershell", "[System.Environment]::SetEnvironmentVariable(\"Path\", \""+newPath+"\", \""+target+"\")")
-	if err := cmd.Run(); err != nil {
+	warned := false
+	err := updatePath(env.ScopeOf(sys), true, func(paths []string) ([]string, error) {
+		if !warned && !slices.Equal(paths, result.Paths) {
+			color.Warning("%s has changed since it was checked, cleaning the current value", Name())
+			warned = true
+		}
+		seen := make(map[string]bool, len(paths))
+		var kept []string
+		for _, p := range paths {
+			key := entryKey(p, conv)
+			if seen[key] || invalid[key] {
+				continue
+			}
+			seen[key] = true
+			kept = append(kept, p)
+		}
+		return kept, nil
+	})
+	if err != nil {
 		return err
 	}
-	color.Success("cleaned %s PATH", scope)
+	color.Done("cleaned %s %s", env.ScopeOf(sys), Name())
 	return nil
 }
 
-func pathExists(p string) bool {
-	p = expandPath(p)
+// errSkip is returned by an edit of updatePath that has nothing to write.
+var errSkip = errors.New("nothing to write")
+
+// maxRebase is how many times updatePath redoes an edit of a PATH that
+// keeps changing while it is being edited.
+const maxRebase = 3
+
+// updatePath writes edit(entries) as the PATH of scope, comparing PATH with
+// the value edit was given right before writing. If it has changed, the
+// edit is redone on the new entries with rebase, or fails with
+// env.ErrConflict without.
+func updatePath(scope env.Scope, rebase bool, edit func(paths []string) ([]string, error)) error {
+	conv := Conventions()
+	for attempt := 0; ; attempt++ {
+		old, _, err := env.CurrentStore().Get(scope, conv.PathKey)
+		if err != nil {
+			return err
+		}
+		newPaths, err := edit(splitAndCleanPath(old.Value, conv.ListSeparator))
+		if err != nil {
+			return err
+		}
+
+		var c env.Changeset
+		setPath(&c, joinPath(newPaths, conv), scope, old.Value)
+		_, err = c.Apply()
+		if !errors.Is(err, env.ErrConflict) || !rebase || attempt == maxRebase {
+			return err
+		}
+		color.Warning("%s %s changed while it was being edited, applying the change again", scope, Name())
+	}
+}
+
+// setPath adds the write of newPath as the PATH of scope to c, only made if
+// PATH still holds old. A new PATH is stored as REG_EXPAND_SZ; an existing
+// one keeps its type, unless entries referencing other variables need it
+// to become REG_EXPAND_SZ.
+func setPath(c *env.Changeset, newPath string, scope env.Scope, old string) {
+	conv := Conventions()
+	typ := env.TypeExpandString
+	if v, ok, err := env.CurrentStore().Get(scope, conv.PathKey); err == nil && ok {
+		typ = v.Type
+	}
+	if typ == env.TypeString && conv.Reference.MatchString(newPath) {
+		typ = env.TypeExpandString
+	}
+	c.SetIf(scope, env.EnvVar{Key: conv.PathKey, Value: newPath, Type: typ}, old)
+}
+
+// pathExists reports whether p exists once its references are expanded.
+func pathExists(p string, x *env.Expander) bool {
+	p = expandPath(p, x)
 	_, err := os.Stat(p)
 	return err == nil
 }
 
-func expandPath(p string) string {
-	p = os.ExpandEnv(p)
-	p = os.Expand(p, os.Getenv)
-	return expandWindowsEnv(p)
+// expandPath expands the store references in p, then any $VAR left over
+// from the process environment.
+func expandPath(p string, x *env.Expander) string {
+	return os.ExpandEnv(x.Expand(p).Value)
 }
 
-// expandWindowsEnv expands Windows-style %VAR% environment variables.
-func expandWindowsEnv(p string) string {
-	for {
-		start := strings.Index(p, "%")
-		if start == -1 {
-			break
-		}
-		end := strings.Index(p[start+1:], "%")
-		if end == -1 {
-			break
+// Conventions returns the conventions of the current store, with PathKey
+// and ListSeparator describing the variable selected with UseVar. The
+// separator is, in order, the one given to UseVar, the one listSeparators
+// has for the variable and the store's list separator.
+func Conventions() env.Conventions {
+	conv := env.ConventionsOf(env.CurrentStore())
+	if target.Key != "" {
+		conv.PathKey = target.Key
+		if sep, ok := listSeparators[strings.ToUpper(target.Key)]; ok {
+			conv.ListSeparator = sep
 		}
-		end += start + 1
-		varName := p[start+1 : end]
-		value := os.Getenv(varName)
-		p = p[:start] + value + p[end+1:]
+	}
+	if target.Separator != "" {
+		conv.ListSeparator = target.Separator
+	}
+	return conv
+}
+
+// entryKey returns the form of a PATH entry used to compare entries.
+func entryKey(p string, conv env.Conventions) string {
+	p = normalizePath(p)
+	if !conv.CaseSensitive {
+		p = strings.ToLower(p)
 	}
 	return p
 }
 
+func joinPath(paths []string, conv env.Conventions) string {
+	return strings.Join(paths, conv.ListSeparator)
+}
+
 // normalizePath removes trailing slashes and trims whitespace.
 func normalizePath(p string) string {
 	p = strings.TrimSpace(p)
@@ -222,25 +300,25 @@ type InvalidPath struct {
 	Path  string
 }
 
-// Check finds invalid paths in PATH that don't exist on filesystem.
+// Check finds invalid paths in PATH that don't exist on filesystem. It
+// fails for a variable that does not hold paths.
 func Check(sys bool) ([]InvalidPath, error) {
-	var (
-		paths []string
-		err   error
-	)
-
-	if sys {
-		paths, err = QuerySystemPath()
-	} else {
-		paths, err = QueryUserPath()
+	if !HoldsPaths() {
+		return nil, fmt.Errorf("%s does not hold paths, there is nothing to check", Name())
+	}
+	paths, err := queryPath(env.ScopeOf(sys))
+	if err != nil {
+		return nil, err
 	}
+
+	x, err := env.NewExpander(env.ScopeOf(sys))
 	if err != nil {
 		return nil, err
 	}
 
 	var invalid []InvalidPath
 	for i, p := range paths {
-		if !pathExists(p) {
+		if !pathExists(p, x) {
 			invalid = append(invalid, InvalidPath{Index: i + 1, Path: p})
 		}
 	}
@@ -249,45 +327,85 @@ func Check(sys bool) ([]InvalidPath, error) {
 
 // RemoveInvalidPaths removes specified invalid paths from PATH.
 func RemoveInvalidPaths(paths []InvalidPath, sys bool) error {
-	var (
-		currentPaths []string
-		err          error
-	)
-
-	if sys {
-		currentPaths, err = QuerySystemPath()
-	} else {
-		currentPaths, err = QueryUserPath()
+	conv := Conventions()
+	toRemove := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		toRemove[entryKey(p.Path, conv)] = true
 	}
+
+	err := updatePath(env.ScopeOf(sys), true, func(currentPaths []string) ([]string, error) {
+		var newPaths []string
+		for _, p := range currentPaths {
+			if toRemove[entryKey(p, conv)] {
+				continue
+			}
+			newPaths = append(newPaths, p)
+		}
+		return newPaths, nil
+	})
 	if err != nil {
 		return err
 	}
+	color.Done("removed %d invalid paths from %s %s", len(paths), env.ScopeOf(sys), Name())
+	return nil
+}
 
-	toRemove := make(map[string]bool, len(paths))
-	for _, p := range paths {
-		toRemove[strings.ToLower(normalizePath(p.Path))] = true
+// Transfer moves a PATH entry from one scope to the end of the other, or
+// copies it with keep. An entry the destination already has is not added
+// again.
+func Transfer(entry string, from, to env.Scope, keep bool) error {
+	conv := Conventions()
+	srcValue, _, err := env.CurrentStore().Get(from, conv.PathKey)
+	if err != nil {
+		return err
 	}
-
-	var newPath string
-	for _, p := range currentPaths {
-		pNorm := strings.ToLower(normalizePath(p))
-		if toRemove[pNorm] {
+	dstValue, _, err := env.CurrentStore().Get(to, conv.PathKey)
+	if err != nil {
+		return err
+	}
+	src := splitAndCleanPath(srcValue.Value, conv.ListSeparator)
+	dst := splitAndCleanPath(dstValue.Value, conv.ListSeparator)
+
+	key := entryKey(entry, conv)
+	var kept []string
+	found := ""
+	for _, p := range src {
+		if entryKey(p, conv) == key {
+			found = p
 			continue
 		}
-		newPath += p + ";"
+		kept = append(kept, p)
+	}
+	if found == "" {
+		return fmt.Errorf("%s %s entry not found: %s", from, Name(), entry)
 	}
 
-	scope := "user"
-	target := "User"
-	if sys {
-		scope = "system"
-		target = "Machine"
+	added := true
+	for _, p := range dst {
+		if entryKey(p, conv) == key {
+			added = false
+			break
+		}
 	}
 
-	cmd := exec.Command("powershell", "[System.Environment]::SetEnvironmentVariable(\"Path\", \""+newPath+"\", \""+target+"\")")
-	if err := cmd.Run(); err != nil {
+	// Both scopes are written as one changeset, so the entry is not left
+	// in both or neither if the second write fails, and neither is written
+	// if one has changed since it was read.
+	var c env.Changeset
+	if added {
+		setPath(&c, joinPath(append(dst, found), conv), to, dstValue.Value)
+	}
+	if !keep {
+		setPath(&c, joinPath(kept, conv), from, srcValue.Value)
+	}
+	if _, err := c.Apply(); err != nil {
 		return err
 	}
-	color.Success("removed %d invalid paths from %s PATH", len(paths), scope)
+
+	verb := "move"
+	if keep {
+		verb = "copy"
+	}
+	color.Done("%s %s [%s -> %s %s]", verb, found, from, to, Name())
 	return nil
 }
@@ -1,9 +1,14 @@
 package path
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/doraemonkeys/menv/env"
 )
 
 func TestNormalizePath(t *testing.T) {
@@ -106,7 +111,7 @@ func TestPathExists(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := pathExists(tt.path)
+			got := pathExists(tt.path, processExpander())
 			if got != tt.want {
 				t.Errorf("pathExists(%q) = %v, want %v", tt.path, got, tt.want)
 			}
@@ -119,22 +124,24 @@ func TestPathExistsWithEnvVar(t *testing.T) {
 	os.Setenv("TEST_MENV_DIR", tempDir)
 	defer os.Unsetenv("TEST_MENV_DIR")
 
-	if !pathExists("$TEST_MENV_DIR") {
+	if !pathExists("$TEST_MENV_DIR", processExpander()) {
 		t.Error("pathExists should expand environment variables with $VAR syntax")
 	}
-	if !pathExists("${TEST_MENV_DIR}") {
+	if !pathExists("${TEST_MENV_DIR}", processExpander()) {
 		t.Error("pathExists should expand environment variables with ${VAR} syntax")
 	}
-	if !pathExists("%TEST_MENV_DIR%") {
+	if !pathExists("%TEST_MENV_DIR%", processExpander()) {
 		t.Error("pathExists should expand environment variables with %VAR% syntax")
 	}
 }
 
-func TestExpandWindowsEnv(t *testing.T) {
+func TestExpandPath(t *testing.T) {
 	os.Setenv("TEST_VAR1", "value1")
 	os.Setenv("TEST_VAR2", "value2")
+	os.Setenv("TEST_VAR3", "process")
 	defer os.Unsetenv("TEST_VAR1")
 	defer os.Unsetenv("TEST_VAR2")
+	defer os.Unsetenv("TEST_VAR3")
 
 	tests := []struct {
 		name  string
@@ -159,25 +166,51 @@ func TestExpandWindowsEnv(t *testing.T) {
 		{
 			name:  "undefined variable",
 			input: "%UNDEFINED_VAR%",
-			want:  "",
+			want:  "%UNDEFINED_VAR%",
 		},
 		{
 			name:  "mixed content",
 			input: "C:\\%TEST_VAR1%\\bin",
 			want:  "C:\\value1\\bin",
 		},
+		{
+			name:  "store variable",
+			input: "%MENV_HOME%\\bin",
+			want:  "C:\\menv\\bin",
+		},
+		{
+			name:  "store variable shadows process",
+			input: "%TEST_VAR3%",
+			want:  "stored",
+		},
+		{
+			name:  "cycle",
+			input: "%LOOP%",
+			want:  "%LOOP%",
+		},
 	}
 
+	x := env.NewExpanderFrom([]env.EnvVar{
+		{Key: "MENV_HOME", Value: "C:\\menv", Type: env.TypeString},
+		{Key: "TEST_VAR3", Value: "stored", Type: env.TypeString},
+		{Key: "LOOP", Value: "%LOOP%", Type: env.TypeExpandString},
+	}, env.ConventionsOf(env.NewMemoryStore()))
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := expandWindowsEnv(tt.input)
+			got := expandPath(tt.input, x)
 			if got != tt.want {
-				t.Errorf("expandWindowsEnv(%q) = %q, want %q", tt.input, got, tt.want)
+				t.Errorf("expandPath(%q) = %q, want %q", tt.input, got, tt.want)
 			}
 		})
 	}
 }
 
+// processExpander returns an Expander that only sees the process environment.
+func processExpander() *env.Expander {
+	return env.NewExpanderFrom(nil, env.ConventionsOf(env.NewMemoryStore()))
+}
+
 func createTempFile(t *testing.T, dir string) string {
 	t.Helper()
 	f, err := os.CreateTemp(dir, "test")
@@ -209,3 +242,436 @@ func TestCheckFunctionReturnsType(t *testing.T) {
 	// Verify return type is correct ([]InvalidPath)
 	_ = result
 }
+
+// useMemoryStore points the env package at a fresh in-memory store for the test.
+func useMemoryStore(t *testing.T, userPath string) *env.MemoryStore {
+	t.Helper()
+	store := env.NewMemoryStore()
+	if userPath != "" {
+		if err := store.Set(env.ScopeUser, env.EnvVar{Key: "Path", Value: userPath, Type: env.TypeExpandString}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prev := env.CurrentStore()
+	env.UseStore(store)
+	t.Cleanup(func() { env.UseStore(prev) })
+	return store
+}
+
+func TestAdd(t *testing.T) {
+	store := useMemoryStore(t, "C:\\bin;D:\\tools\\")
+
+	if err := Add("E:\\new\\", false); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := Add("C:\\bin", false); err != nil {
+		t.Fatalf("Add() of existing path error = %v", err)
+	}
+
+	got, _, _ := store.Get(env.ScopeUser, "Path")
+	if want := "C:\\bin;D:\\tools;E:\\new"; got.Value != want {
+		t.Errorf("Path = %q, want %q", got.Value, want)
+	}
+	if got.Type != env.TypeExpandString {
+		t.Errorf("Path type = %s, want %s", got.Type, env.TypeExpandString)
+	}
+
+	if err := Add("C:\\a;C:\\b", false); err == nil {
+		t.Error("Add() expected error for path containing ';'")
+	}
+}
+
+func TestAdd_ReferenceMakesPathExpandable(t *testing.T) {
+	store := useMemoryStore(t, "")
+	_ = store.Set(env.ScopeUser, env.EnvVar{Key: "Path", Value: "C:\\bin", Type: env.TypeString})
+
+	if err := Add("%USERPROFILE%\\bin", false); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if got, _, _ := store.Get(env.ScopeUser, "Path"); got.Type != env.TypeExpandString {
+		t.Errorf("Path type = %s, want %s for a %%VAR%% entry", got.Type, env.TypeExpandString)
+	}
+}
+
+func TestAdd_MissingPath(t *testing.T) {
+	store := useMemoryStore(t, "")
+
+	if err := Add("C:\\bin", true); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	got, ok, _ := store.Get(env.ScopeSystem, "Path")
+	if !ok || got.Value != "C:\\bin" {
+		t.Errorf("system Path = %q, want %q", got.Value, "C:\\bin")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	store := useMemoryStore(t, "C:\\bin;D:\\Tools")
+
+	if err := Remove("d:\\tools\\", false); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := Remove("E:\\missing", false); err != nil {
+		t.Fatalf("Remove() of missing path error = %v", err)
+	}
+
+	got, _, _ := store.Get(env.ScopeUser, "Path")
+	if want := "C:\\bin"; got.Value != want {
+		t.Errorf("Path = %q, want %q", got.Value, want)
+	}
+}
+
+func TestPreviewAndApplyClean(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	store := useMemoryStore(t, dir+";"+missing+";"+dir+string(filepath.Separator))
+
+	result, err := PreviewClean(false)
+	if err != nil {
+		t.Fatalf("PreviewClean() error = %v", err)
+	}
+	if len(result.Duplicates) != 1 || len(result.Invalid) != 1 {
+		t.Errorf("PreviewClean() = %+v, want 1 duplicate and 1 invalid", result)
+	}
+	if want := dir; result.NewPath != want {
+		t.Errorf("PreviewClean().NewPath = %q, want %q", result.NewPath, want)
+	}
+
+	if err := ApplyClean(result, false); err != nil {
+		t.Fatalf("ApplyClean() error = %v", err)
+	}
+	got, _, _ := store.Get(env.ScopeUser, "Path")
+	if got.Value != result.NewPath {
+		t.Errorf("Path = %q, want %q", got.Value, result.NewPath)
+	}
+}
+
+func TestCheckAndRemoveInvalidPaths(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	store := useMemoryStore(t, dir+";"+missing)
+
+	invalid, err := Check(false)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(invalid) != 1 || invalid[0].Index != 2 || invalid[0].Path != missing {
+		t.Fatalf("Check() = %v, want [{2 %s}]", invalid, missing)
+	}
+
+	if err := RemoveInvalidPaths(invalid, false); err != nil {
+		t.Fatalf("RemoveInvalidPaths() error = %v", err)
+	}
+	got, _, _ := store.Get(env.ScopeUser, "Path")
+	if want := dir; got.Value != want {
+		t.Errorf("Path = %q, want %q", got.Value, want)
+	}
+}
+
+func TestAddRemove_ProfileStore(t *testing.T) {
+	dir := t.TempDir()
+	store := env.NewProfileStore(filepath.Join(dir, ".profile"), filepath.Join(dir, "environment"))
+	prev := env.CurrentStore()
+	env.UseStore(store)
+	t.Cleanup(func() { env.UseStore(prev) })
+
+	for _, p := range []string{"/opt/a/", "/opt/B", "/opt/b"} {
+		if err := Add(p, false); err != nil {
+			t.Fatalf("Add(%q) error = %v", p, err)
+		}
+	}
+	if err := Add("/x:/y", false); err == nil {
+		t.Error("Add() expected error for path containing ':'")
+	}
+
+	paths, err := QueryUserPath()
+	if err != nil {
+		t.Fatalf("QueryUserPath() error = %v", err)
+	}
+	if want := []string{"/opt/a", "/opt/B", "/opt/b"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("QueryUserPath() = %v, want %v (case-sensitive entries)", paths, want)
+	}
+
+	if err := Remove("/opt/B", false); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	v, _, _ := store.Get(env.ScopeUser, "PATH")
+	if want := "/opt/a:/opt/b"; v.Value != want {
+		t.Errorf("PATH = %q, want %q", v.Value, want)
+	}
+}
+
+func TestPreviewClean_EnvironmentDStore(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	store := env.NewEnvironmentDStore(filepath.Join(dir, "menv.conf"))
+	if err := store.Set(env.ScopeUser, env.EnvVar{Key: "PATH", Value: dir + ":" + missing + ":" + dir + "/"}); err != nil {
+		t.Fatal(err)
+	}
+	prev := env.CurrentStore()
+	env.UseStore(store)
+	t.Cleanup(func() { env.UseStore(prev) })
+
+	result, err := PreviewClean(false)
+	if err != nil {
+		t.Fatalf("PreviewClean() error = %v", err)
+	}
+	if len(result.Duplicates) != 1 || len(result.Invalid) != 1 || result.Invalid[0] != missing {
+		t.Errorf("PreviewClean() = %+v, want 1 duplicate and %s invalid", result, missing)
+	}
+	if result.NewPath != dir {
+		t.Errorf("PreviewClean().NewPath = %q, want %q", result.NewPath, dir)
+	}
+}
+
+// useVar selects v for the rest of the test.
+func useVar(t *testing.T, v ListVar) {
+	t.Helper()
+	UseVar(v)
+	t.Cleanup(func() { UseVar(ListVar{}) })
+}
+
+func TestAddRemove_ListVar(t *testing.T) {
+	store := useMemoryStore(t, "C:\\bin")
+	_ = store.Set(env.ScopeUser, env.EnvVar{Key: "PSModulePath", Value: "C:\\mods;%USERPROFILE%\\Documents\\PowerShell\\Modules", Type: env.TypeExpandString})
+	useVar(t, ListVar{Key: "PSModulePath"})
+
+	if err := Add("D:\\mods\\", false); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := Add("c:\\MODS", false); err != nil {
+		t.Fatalf("Add() of existing entry error = %v", err)
+	}
+	if got, _, _ := store.Get(env.ScopeUser, "PSModulePath"); strings.Count(got.Value, ";") != 2 {
+		t.Errorf("PSModulePath = %q after adding an existing entry in other case", got.Value)
+	}
+	if err := Remove("C:\\mods", false); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	got, _, _ := store.Get(env.ScopeUser, "PSModulePath")
+	want := env.EnvVar{Key: "PSModulePath", Value: "%USERPROFILE%\\Documents\\PowerShell\\Modules;D:\\mods", Type: env.TypeExpandString}
+	if got != want {
+		t.Errorf("PSModulePath = %v, want %v", got, want)
+	}
+	if p, _, _ := store.Get(env.ScopeUser, "Path"); p.Value != "C:\\bin" {
+		t.Errorf("Path = %q, want it untouched", p.Value)
+	}
+	if Name() != "PSModulePath" {
+		t.Errorf("Name() = %q, want PSModulePath", Name())
+	}
+}
+
+func TestPreviewClean_ListVarSeparator(t *testing.T) {
+	dir := t.TempDir()
+	jar := createTempFile(t, dir)
+	missing := filepath.Join(dir, "missing.jar")
+
+	store := useMemoryStore(t, "")
+	_ = store.Set(env.ScopeUser, env.EnvVar{Key: "CLASSPATH", Value: strings.Join([]string{jar, missing, dir, jar}, "|"), Type: env.TypeString})
+	useVar(t, ListVar{Key: "CLASSPATH", Separator: "|"})
+
+	result, err := PreviewClean(false)
+	if err != nil {
+		t.Fatalf("PreviewClean() error = %v", err)
+	}
+	if want := []string{jar}; !reflect.DeepEqual(result.Duplicates, want) {
+		t.Errorf("Duplicates = %v, want %v", result.Duplicates, want)
+	}
+	if want := []string{missing}; !reflect.DeepEqual(result.Invalid, want) {
+		t.Errorf("Invalid = %v, want %v", result.Invalid, want)
+	}
+	if want := jar + "|" + dir; result.NewPath != want {
+		t.Errorf("NewPath = %q, want %q", result.NewPath, want)
+	}
+
+	if err := Add("a|b", false); err == nil {
+		t.Error("Add() of an entry containing the separator succeeded")
+	}
+}
+
+// TestCleanAndCheck_NotPaths checks that the entries of NO_PROXY, which are
+// hosts rather than paths, are never removed as missing.
+func TestCleanAndCheck_NotPaths(t *testing.T) {
+	store := useMemoryStore(t, "")
+	noProxy := "localhost,127.0.0.1,.corp.example.com,localhost"
+	_ = store.Set(env.ScopeUser, env.EnvVar{Key: "NO_PROXY", Value: noProxy})
+
+	for _, v := range []ListVar{{Key: "NO_PROXY"}, {Key: "no_proxy", Separator: ";"}} {
+		useVar(t, v)
+		if _, err := Check(false); err == nil {
+			t.Errorf("Check() of %+v succeeded, want an error", v)
+		}
+	}
+
+	useVar(t, ListVar{Key: "NO_PROXY"})
+	result, err := PreviewClean(false)
+	if err != nil {
+		t.Fatalf("PreviewClean() error = %v", err)
+	}
+	if len(result.Invalid) != 0 || !reflect.DeepEqual(result.Duplicates, []string{"localhost"}) {
+		t.Errorf("PreviewClean() = %+v, want only the duplicate localhost", result)
+	}
+	if err := ApplyClean(result, false); err != nil {
+		t.Fatalf("ApplyClean() error = %v", err)
+	}
+	if got, _, _ := store.Get(env.ScopeUser, "NO_PROXY"); got.Value != "localhost,127.0.0.1,.corp.example.com" {
+		t.Errorf("NO_PROXY = %q, want the hosts kept", got.Value)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	tests := []struct {
+		name       string
+		entry      string
+		from, to   env.Scope
+		keep       bool
+		wantUser   string
+		wantSystem string
+		wantErr    bool
+	}{
+		{name: "promote", entry: "c:\\tools\\", from: env.ScopeUser, to: env.ScopeSystem, wantUser: "C:\\bin", wantSystem: "C:\\Windows;C:\\tools"},
+		{name: "demote", entry: "C:\\Windows", from: env.ScopeSystem, to: env.ScopeUser, wantUser: "C:\\bin;C:\\tools;C:\\Windows", wantSystem: ""},
+		{name: "copy", entry: "C:\\tools", from: env.ScopeUser, to: env.ScopeSystem, keep: true, wantUser: "C:\\bin;C:\\tools", wantSystem: "C:\\Windows;C:\\tools"},
+		{name: "missing", entry: "C:\\nope", from: env.ScopeUser, to: env.ScopeSystem, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useMemoryStore(t, "C:\\bin;C:\\tools")
+			_ = store.Set(env.ScopeSystem, env.EnvVar{Key: "Path", Value: "C:\\Windows", Type: env.TypeExpandString})
+
+			err := Transfer(tt.entry, tt.from, tt.to, tt.keep)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transfer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			user, _, _ := store.Get(env.ScopeUser, "Path")
+			system, _, _ := store.Get(env.ScopeSystem, "Path")
+			if user.Value != tt.wantUser || system.Value != tt.wantSystem {
+				t.Errorf("user Path = %q, system Path = %q, want %q, %q", user.Value, system.Value, tt.wantUser, tt.wantSystem)
+			}
+		})
+	}
+}
+
+func TestTransfer_AlreadyAtDestination(t *testing.T) {
+	store := useMemoryStore(t, "C:\\bin;C:\\Windows")
+	_ = store.Set(env.ScopeSystem, env.EnvVar{Key: "Path", Value: "C:\\Windows", Type: env.TypeExpandString})
+
+	if err := Transfer("C:\\Windows", env.ScopeUser, env.ScopeSystem, false); err != nil {
+		t.Fatal(err)
+	}
+	user, _, _ := store.Get(env.ScopeUser, "Path")
+	system, _, _ := store.Get(env.ScopeSystem, "Path")
+	if user.Value != "C:\\bin" || system.Value != "C:\\Windows" {
+		t.Errorf("user Path = %q, system Path = %q", user.Value, system.Value)
+	}
+}
+
+// racingStore runs race once, right after the first read of the wrapped
+// store, like another program changing PATH while menv edits it.
+type racingStore struct {
+	env.Store
+	race func()
+}
+
+func (s *racingStore) Get(scope env.Scope, key string) (env.EnvVar, bool, error) {
+	v, ok, err := s.Store.Get(scope, key)
+	if race := s.race; race != nil {
+		s.race = nil
+		race()
+	}
+	return v, ok, err
+}
+
+func useRacingStore(t *testing.T, userPath string, race func(*env.MemoryStore)) *env.MemoryStore {
+	t.Helper()
+	store := useMemoryStore(t, userPath)
+	env.UseStore(&racingStore{Store: store, race: func() { race(store) }})
+	return store
+}
+
+func installerAdds(entry string) func(*env.MemoryStore) {
+	return func(store *env.MemoryStore) {
+		v, _, _ := store.Get(env.ScopeUser, "Path")
+		v.Value += ";" + entry
+		_ = store.Set(env.ScopeUser, v)
+	}
+}
+
+func TestAdd_RebasesOnConcurrentChange(t *testing.T) {
+	store := useRacingStore(t, `C:\bin`, installerAdds(`C:\installer`))
+
+	if err := AddAt(`C:\new`, Position{Front: true}, false); err != nil {
+		t.Fatalf("AddAt() error = %v", err)
+	}
+	got, _, _ := store.Get(env.ScopeUser, "Path")
+	if want := `C:\new;C:\bin;C:\installer`; got.Value != want {
+		t.Errorf("Path = %q, want %q", got.Value, want)
+	}
+}
+
+func TestRemove_RebasesOnConcurrentChange(t *testing.T) {
+	store := useRacingStore(t, `C:\bin;C:\old`, installerAdds(`C:\installer`))
+
+	if err := Remove(`C:\old`, false); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	got, _, _ := store.Get(env.ScopeUser, "Path")
+	if want := `C:\bin;C:\installer`; got.Value != want {
+		t.Errorf("Path = %q, want %q", got.Value, want)
+	}
+}
+
+func TestApplyClean_KeepsEntriesAddedSincePreview(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	store := useMemoryStore(t, dir+";"+missing+";"+dir)
+
+	result, err := PreviewClean(false)
+	if err != nil {
+		t.Fatalf("PreviewClean() error = %v", err)
+	}
+	// An installer adds an entry while the user reads the preview.
+	installerAdds(`C:\installer`)(store)
+
+	if err := ApplyClean(result, false); err != nil {
+		t.Fatalf("ApplyClean() error = %v", err)
+	}
+	got, _, _ := store.Get(env.ScopeUser, "Path")
+	if want := dir + `;C:\installer`; got.Value != want {
+		t.Errorf("Path = %q, want %q", got.Value, want)
+	}
+}
+
+func TestMove_ConflictOnConcurrentChange(t *testing.T) {
+	store := useRacingStore(t, `C:\a;C:\b`, installerAdds(`C:\installer`))
+
+	if _, err := Move("2", 1, false); !errors.Is(err, env.ErrConflict) {
+		t.Fatalf("Move() error = %v, want ErrConflict", err)
+	}
+	got, _, _ := store.Get(env.ScopeUser, "Path")
+	if want := `C:\a;C:\b;C:\installer`; got.Value != want {
+		t.Errorf("Path = %q, want %q", got.Value, want)
+	}
+}
+
+func TestUpdatePath_GivesUpOnConstantChanges(t *testing.T) {
+	store := useMemoryStore(t, `C:\a`)
+	n := 0
+	err := updatePath(env.ScopeUser, true, func(paths []string) ([]string, error) {
+		n++
+		installerAdds(`C:\other`)(store)
+		return append(paths, `C:\new`), nil
+	})
+	if !errors.Is(err, env.ErrConflict) {
+		t.Fatalf("updatePath() error = %v, want ErrConflict", err)
+	}
+	if n != maxRebase+1 {
+		t.Errorf("edit called %d times, want %d", n, maxRebase+1)
+	}
+}
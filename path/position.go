@@ -0,0 +1,142 @@
+package path
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/doraemonkeys/menv/env"
+)
+
+// Position is where AddAt puts an entry. The zero Position appends.
+type Position struct {
+	// Front inserts before all other entries.
+	Front bool
+	// At is the 1-based index the entry gets; 0 means unset.
+	At int
+	// Before and After insert next to an existing entry.
+	Before, After string
+	// Move moves an entry that already exists to the position instead of
+	// leaving it where it is.
+	Move bool
+}
+
+func (pos Position) validate() error {
+	set := 0
+	for _, ok := range []bool{pos.Front, pos.At != 0, pos.Before != "", pos.After != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return errors.New("only one of -front, -at, -before and -after can be used")
+	}
+	if pos.At < 0 {
+		return fmt.Errorf("invalid position %d", pos.At)
+	}
+	return nil
+}
+
+// index returns the index in paths at which pos inserts an entry.
+func (pos Position) index(paths []string) (int, error) {
+	conv := Conventions()
+	switch {
+	case pos.Front:
+		return 0, nil
+	case pos.At != 0:
+		if pos.At > len(paths)+1 {
+			return 0, fmt.Errorf("position %d out of range 1-%d", pos.At, len(paths)+1)
+		}
+		return pos.At - 1, nil
+	case pos.Before != "" || pos.After != "":
+		ref := pos.Before + pos.After
+		refKey := entryKey(ref, conv)
+		for i, p := range paths {
+			if entryKey(p, conv) == refKey {
+				if pos.After != "" {
+					i++
+				}
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("%s entry not found: %s", Name(), ref)
+	default:
+		return len(paths), nil
+	}
+}
+
+// insertAt returns paths with entry inserted at index i.
+func insertAt(paths []string, i int, entry string) []string {
+	result := make([]string, 0, len(paths)+1)
+	result = append(result, paths[:i]...)
+	result = append(result, entry)
+	return append(result, paths[i:]...)
+}
+
+// MoveResult is the order of the entries before and after a move.
+type MoveResult struct {
+	Before, After []string
+	// From and To are the 1-based indices of the moved entry.
+	From, To int
+}
+
+// Move moves an entry, given as a path or its 1-based index, so that it
+// becomes entry to, and writes the result once.
+func Move(entry string, to int, sys bool) (MoveResult, error) {
+	return move(entry, sys, func(int, int) int { return to })
+}
+
+// Shift moves an entry, given as a path or its 1-based index, by delta
+// places: -1 moves it up one place, 1 down one place.
+func Shift(entry string, delta int, sys bool) (MoveResult, error) {
+	return move(entry, sys, func(from, _ int) int { return from + delta })
+}
+
+func move(entry string, sys bool, target func(from, n int) int) (MoveResult, error) {
+	// An entry given by index may be another one once PATH has changed, so
+	// a move is not redone on a changed PATH but fails with env.ErrConflict.
+	var result MoveResult
+	err := updatePath(env.ScopeOf(sys), false, func(paths []string) ([]string, error) {
+		from, err := findEntry(paths, entry)
+		if err != nil {
+			return nil, err
+		}
+		to := target(from, len(paths))
+		if to < 1 || to > len(paths) {
+			return nil, fmt.Errorf("position %d out of range 1-%d", to, len(paths))
+		}
+
+		result = MoveResult{Before: paths, From: from, To: to}
+		rest := make([]string, 0, len(paths))
+		rest = append(rest, paths[:from-1]...)
+		rest = append(rest, paths[from:]...)
+		result.After = insertAt(rest, to-1, paths[from-1])
+		if from == to {
+			return nil, errSkip
+		}
+		return result.After, nil
+	})
+	if err != nil && !errors.Is(err, errSkip) {
+		return MoveResult{}, err
+	}
+	return result, nil
+}
+
+// findEntry returns the 1-based index of entry in paths. entry is either an
+// index, as shown by -path, or the entry itself.
+func findEntry(paths []string, entry string) (int, error) {
+	if i, err := strconv.Atoi(entry); err == nil {
+		if i < 1 || i > len(paths) {
+			return 0, fmt.Errorf("index %d out of range 1-%d", i, len(paths))
+		}
+		return i, nil
+	}
+	conv := Conventions()
+	key := entryKey(entry, conv)
+	for i, p := range paths {
+		if entryKey(p, conv) == key {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("%s entry not found: %s", Name(), entry)
+}
@@ -0,0 +1,123 @@
+package path
+
+import (
+	"testing"
+
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestAddAt(t *testing.T) {
+	const initial = "C:\\a;C:\\b;C:\\WindowsApps"
+
+	tests := []struct {
+		name    string
+		add     string
+		pos     Position
+		want    string
+		wantErr bool
+	}{
+		{name: "append", add: "C:\\new", want: initial + ";C:\\new"},
+		{name: "front", add: "C:\\Python", pos: Position{Front: true}, want: "C:\\Python;" + initial},
+		{name: "at 1", add: "C:\\new", pos: Position{At: 1}, want: "C:\\new;" + initial},
+		{name: "at 3", add: "C:\\new", pos: Position{At: 3}, want: "C:\\a;C:\\b;C:\\new;C:\\WindowsApps"},
+		{name: "at end", add: "C:\\new", pos: Position{At: 4}, want: initial + ";C:\\new"},
+		{name: "at out of range", add: "C:\\new", pos: Position{At: 5}, wantErr: true},
+		{name: "before", add: "C:\\Python", pos: Position{Before: "c:\\windowsapps\\"}, want: "C:\\a;C:\\b;C:\\Python;C:\\WindowsApps"},
+		{name: "after", add: "C:\\new", pos: Position{After: "C:\\a"}, want: "C:\\a;C:\\new;C:\\b;C:\\WindowsApps"},
+		{name: "before missing entry", add: "C:\\new", pos: Position{Before: "C:\\x"}, wantErr: true},
+		{name: "two positions", add: "C:\\new", pos: Position{Front: true, At: 2}, wantErr: true},
+		{name: "existing skipped", add: "C:\\b", pos: Position{Front: true}, want: initial},
+		{name: "existing moved to front", add: "c:\\B", pos: Position{Front: true, Move: true}, want: "c:\\B;C:\\a;C:\\WindowsApps"},
+		{name: "existing moved to end", add: "C:\\a", pos: Position{Move: true}, want: "C:\\b;C:\\WindowsApps;C:\\a"},
+		{name: "existing moved after", add: "C:\\WindowsApps", pos: Position{After: "C:\\a", Move: true}, want: "C:\\a;C:\\WindowsApps;C:\\b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useMemoryStore(t, initial)
+			err := AddAt(tt.add, tt.pos, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AddAt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, _, _ := store.Get(env.ScopeUser, "Path")
+			if got.Value != tt.want {
+				t.Errorf("Path = %q, want %q", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveAndShift(t *testing.T) {
+	const initial = "C:\\a;C:\\b;C:\\c"
+
+	tests := []struct {
+		name     string
+		move     func() (MoveResult, error)
+		want     string
+		from, to int
+		wantErr  bool
+	}{
+		{name: "index to front", move: func() (MoveResult, error) { return Move("3", 1, false) }, want: "C:\\c;C:\\a;C:\\b", from: 3, to: 1},
+		{name: "entry to end", move: func() (MoveResult, error) { return Move("c:\\A\\", 3, false) }, want: "C:\\b;C:\\c;C:\\a", from: 1, to: 3},
+		{name: "same position", move: func() (MoveResult, error) { return Move("2", 2, false) }, want: initial, from: 2, to: 2},
+		{name: "up", move: func() (MoveResult, error) { return Shift("C:\\b", -1, false) }, want: "C:\\b;C:\\a;C:\\c", from: 2, to: 1},
+		{name: "down", move: func() (MoveResult, error) { return Shift("C:\\b", 1, false) }, want: "C:\\a;C:\\c;C:\\b", from: 2, to: 3},
+		{name: "up from top", move: func() (MoveResult, error) { return Shift("1", -1, false) }, wantErr: true},
+		{name: "position out of range", move: func() (MoveResult, error) { return Move("1", 4, false) }, wantErr: true},
+		{name: "index out of range", move: func() (MoveResult, error) { return Move("0", 1, false) }, wantErr: true},
+		{name: "missing entry", move: func() (MoveResult, error) { return Move("C:\\x", 1, false) }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useMemoryStore(t, initial)
+			result, err := tt.move()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, _, _ := store.Get(env.ScopeUser, "Path")
+			if tt.wantErr {
+				if got.Value != initial {
+					t.Errorf("Path = %q after failed move, want unchanged", got.Value)
+				}
+				return
+			}
+			if got.Value != tt.want {
+				t.Errorf("Path = %q, want %q", got.Value, tt.want)
+			}
+			if result.From != tt.from || result.To != tt.to {
+				t.Errorf("moved %d -> %d, want %d -> %d", result.From, result.To, tt.from, tt.to)
+			}
+			if joinPath(result.Before, Conventions()) != initial || joinPath(result.After, Conventions()) != tt.want {
+				t.Errorf("result = %+v", result)
+			}
+		})
+	}
+}
+
+func TestMove_WritesOnce(t *testing.T) {
+	useMemoryStore(t, "C:\\a;C:\\b;C:\\c")
+	counter := &countingStore{Store: env.CurrentStore()}
+	env.UseStore(counter)
+
+	if _, err := Move("3", 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if counter.sets != 1 {
+		t.Errorf("Move() wrote %d times, want 1", counter.sets)
+	}
+}
+
+// countingStore counts the writes that reach the wrapped store.
+type countingStore struct {
+	env.Store
+	sets int
+}
+
+func (s *countingStore) Set(scope env.Scope, v env.EnvVar) error {
+	s.sets++
+	return s.Store.Set(scope, v)
+}
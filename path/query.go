@@ -1,57 +1,108 @@
 package path
 
 import (
-	"errors"
-	"os/exec"
 	"strings"
-)
 
-const (
-	userEnvRegPath   = "HKEY_CURRENT_USER\\Environment"
-	systemEnvRegPath = "HKEY_LOCAL_MACHINE\\SYSTEM\\CurrentControlSet\\Control\\Session Manager\\Environment"
+	"github.com/doraemonkeys/menv/env"
 )
 
-// QueryUserPath queries the user's PATH environment variable from registry.
-func QueryUserPath() ([]string, error) {
-	pathCmd := exec.Command("reg", "query", userEnvRegPath, "/v", "Path")
-	pathByte, err := pathCmd.Output()
-	if err != nil {
-		return nil, err
+// ListVar is a variable holding a separator-delimited list, like PATH.
+type ListVar struct {
+	// Key is the variable name; empty selects the store's PATH.
+	Key string
+	// Separator separates the entries; empty selects the store's list
+	// separator.
+	Separator string
+}
+
+// listSeparators holds the separators of well-known list variables that do
+// not use the store's list separator, keyed by upper-case name. Their
+// entries are hosts, URLs or flags rather than paths.
+var listSeparators = map[string]string{
+	"NO_PROXY":  ",",
+	"GOPROXY":   ",",
+	"GOPRIVATE": ",",
+	"GONOPROXY": ",",
+	"GONOSUMDB": ",",
+	"GOFLAGS":   " ",
+}
+
+// target is the variable the package works on.
+var target ListVar
+
+// UseVar makes the package query and edit v instead of PATH, e.g.
+// PSModulePath or CLASSPATH. The zero ListVar selects PATH again.
+func UseVar(v ListVar) {
+	target = v
+}
+
+// HoldsPaths reports whether the entries of the selected variable are
+// paths, which -check and -clean can test for existence.
+func HoldsPaths() bool {
+	_, ok := listSeparators[strings.ToUpper(target.Key)]
+	return !ok
+}
+
+// Name returns the name of the selected variable for messages.
+func Name() string {
+	if target.Key == "" {
+		return "PATH"
 	}
-	return parsePathOutput(string(pathByte))
+	return target.Key
+}
+
+// QueryUserPath queries the user's PATH environment variable from the current store.
+func QueryUserPath() ([]string, error) {
+	return queryPath(env.ScopeUser)
 }
 
-// QuerySystemPath queries the system PATH environment variable from registry.
+// QuerySystemPath queries the system PATH environment variable from the current store.
 func QuerySystemPath() ([]string, error) {
-	pathCmd := exec.Command("reg", "query", systemEnvRegPath, "/v", "Path")
-	pathByte, err := pathCmd.Output()
+	return queryPath(env.ScopeSystem)
+}
+
+func queryPath(scope env.Scope) ([]string, error) {
+	conv := Conventions()
+	v, _, err := env.CurrentStore().Get(scope, conv.PathKey)
 	if err != nil {
 		return nil, err
 	}
-	return parsePathOutput(string(pathByte))
+	return splitAndCleanPath(v.Value, conv.ListSeparator), nil
 }
 
-func parsePathOutput(output string) ([]string, error) {
-	output = strings.TrimSpace(output)
+// PathEntry is an entry of the effective PATH with the scope it came from.
+type PathEntry struct {
+	Path   string
+	Source env.Scope
+}
 
-	// Find Path line and extract value after REG_SZ or REG_EXPAND_SZ
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if !strings.HasPrefix(strings.ToLower(line), "path") {
-			continue
+// QueryEffectivePath returns the PATH new processes see: the system
+// entries followed by the user entries. For variables that are not
+// concatenated like PATH, a user value replaces the system value.
+func QueryEffectivePath() ([]PathEntry, error) {
+	scopes := []env.Scope{env.ScopeSystem, env.ScopeUser}
+	key := Conventions().PathKey
+	if !env.IsConcatenated(key, env.ConventionsOf(env.CurrentStore())) {
+		_, ok, err := env.CurrentStore().Get(env.ScopeUser, key)
+		if err != nil {
+			return nil, err
 		}
-
-		// Try REG_SZ first, then REG_EXPAND_SZ
-		for _, regType := range []string{"REG_EXPAND_SZ", "REG_SZ"} {
-			if idx := strings.Index(line, regType); idx != -1 {
-				pathValue := strings.TrimSpace(line[idx+len(regType):])
-				return splitAndCleanPath(pathValue), nil
-			}
+		if ok {
+			scopes = scopes[1:]
 		}
 	}
 
-	return nil, errors.New("query path failed")
+	var entries []PathEntry
+	for _, scope := range scopes {
+		paths, err := queryPath(scope)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			entries = append(entries, PathEntry{Path: p, Source: scope})
+		}
+	}
+	return entries, nil
 }
 
 // SearchUserPath searches user PATH for entries containing keyword (case-insensitive).
@@ -83,9 +134,9 @@ func filterPaths(paths []string, keyword string) []string {
 	return result
 }
 
-// splitAndCleanPath splits path by semicolon and removes empty entries.
-func splitAndCleanPath(path string) []string {
-	paths := strings.Split(path, ";")
+// splitAndCleanPath splits path by sep and removes empty entries.
+func splitAndCleanPath(path, sep string) []string {
+	paths := strings.Split(path, sep)
 	result := make([]string, 0, len(paths))
 
 	for _, p := range paths {
@@ -3,6 +3,8 @@ package path
 import (
 	"reflect"
 	"testing"
+
+	"github.com/doraemonkeys/menv/env"
 )
 
 func TestSplitAndCleanPath(t *testing.T) {
@@ -55,10 +57,94 @@ func TestSplitAndCleanPath(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := splitAndCleanPath(tt.path)
+			got := splitAndCleanPath(tt.path, ";")
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("splitAndCleanPath() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestQueryEffectivePath(t *testing.T) {
+	store := useMemoryStore(t, "C:\\bin")
+	if err := store.Set(env.ScopeSystem, env.EnvVar{Key: "Path", Value: "C:\\Windows;C:\\Windows\\System32"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := QueryEffectivePath()
+	if err != nil {
+		t.Fatalf("QueryEffectivePath() error = %v", err)
+	}
+	want := []PathEntry{
+		{Path: "C:\\Windows", Source: env.ScopeSystem},
+		{Path: "C:\\Windows\\System32", Source: env.ScopeSystem},
+		{Path: "C:\\bin", Source: env.ScopeUser},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryEffectivePath() = %v, want %v", got, want)
+	}
+}
+
+func TestQueryEffectivePath_UserReplacesSystem(t *testing.T) {
+	store := useMemoryStore(t, "")
+	_ = store.Set(env.ScopeSystem, env.EnvVar{Key: "PSModulePath", Value: "C:\\Windows\\Modules"})
+	useVar(t, ListVar{Key: "PSModulePath"})
+
+	got, err := QueryEffectivePath()
+	if err != nil {
+		t.Fatalf("QueryEffectivePath() error = %v", err)
+	}
+	if want := []PathEntry{{Path: "C:\\Windows\\Modules", Source: env.ScopeSystem}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryEffectivePath() without a user value = %v, want %v", got, want)
+	}
+
+	_ = store.Set(env.ScopeUser, env.EnvVar{Key: "PSModulePath", Value: "D:\\mods"})
+	got, err = QueryEffectivePath()
+	if err != nil {
+		t.Fatalf("QueryEffectivePath() error = %v", err)
+	}
+	if want := []PathEntry{{Path: "D:\\mods", Source: env.ScopeUser}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("QueryEffectivePath() = %v, want only the user value %v", got, want)
+	}
+}
+
+func TestConventions_ListSeparators(t *testing.T) {
+	useMemoryStore(t, "")
+
+	tests := []struct {
+		v    ListVar
+		want string
+	}{
+		{ListVar{}, ";"},
+		{ListVar{Key: "CLASSPATH"}, ";"},
+		{ListVar{Key: "no_proxy"}, ","},
+		{ListVar{Key: "GOFLAGS"}, " "},
+		{ListVar{Key: "NO_PROXY", Separator: ";"}, ";"},
+	}
+	for _, tt := range tests {
+		useVar(t, tt.v)
+		if got := Conventions().ListSeparator; got != tt.want {
+			t.Errorf("Conventions(%+v).ListSeparator = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPath(t *testing.T) {
+	store := useMemoryStore(t, "C:\\Python313;C:\\bin;C:\\Python313\\Scripts")
+	_ = store.Set(env.ScopeSystem, env.EnvVar{Key: "Path", Value: "C:\\Windows;C:\\Program Files\\python"})
+
+	got, err := SearchUserPath("python")
+	if err != nil {
+		t.Fatalf("SearchUserPath() error = %v", err)
+	}
+	if want := []string{"C:\\Python313", "C:\\Python313\\Scripts"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchUserPath() = %v, want %v", got, want)
+	}
+	got, err = SearchSystemPath("PYTHON")
+	if err != nil {
+		t.Fatalf("SearchSystemPath() error = %v", err)
+	}
+	if want := []string{"C:\\Program Files\\python"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchSystemPath() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/env"
+	"github.com/doraemonkeys/menv/path"
+)
+
+// addPosition returns the position given by -front, -at, -before, -after
+// and -move.
+func addPosition() (path.Position, error) {
+	pos := path.Position{
+		Front:  *cmd.Front,
+		Before: *cmd.Before,
+		After:  *cmd.After,
+		Move:   *cmd.Move,
+	}
+	if *cmd.At != "" {
+		at, err := strconv.Atoi(*cmd.At)
+		if err != nil || at < 1 {
+			return pos, fmt.Errorf("invalid position for -at: %s", *cmd.At)
+		}
+		pos.At = at
+	}
+	return pos, nil
+}
+
+// movePath moves the -mv entry to the position in args, or one place with
+// -up or -down, and prints the order before and after.
+func movePath(args []string) error {
+	var result path.MoveResult
+	var err error
+	switch {
+	case *cmd.Up && *cmd.Down:
+		return fmt.Errorf("-up and -down cannot be combined")
+	case *cmd.Up || *cmd.Down:
+		if len(args) != 0 {
+			return fmt.Errorf("unexpected arguments: %v", args)
+		}
+		delta := 1
+		if *cmd.Up {
+			delta = -1
+		}
+		result, err = path.Shift(*cmd.MovePath, delta, *cmd.SetSystem)
+	default:
+		if len(args) != 1 {
+			return fmt.Errorf("usage: menv -mv <entry|index> <position>")
+		}
+		to, convErr := strconv.Atoi(args[0])
+		if convErr != nil {
+			return fmt.Errorf("invalid position: %s", args[0])
+		}
+		result, err = path.Move(*cmd.MovePath, to, *cmd.SetSystem)
+	}
+	if err != nil {
+		return err
+	}
+
+	scope := env.ScopeOf(*cmd.SetSystem)
+	color.Info("Before:")
+	printMoveOrder(result.Before, result.From)
+	color.Info("After:")
+	printMoveOrder(result.After, result.To)
+	if result.From == result.To {
+		color.Warning("%s is already at position %d", result.After[result.To-1], result.To)
+		return nil
+	}
+	color.Done("moved %s from %d to %d [%s %s]", result.After[result.To-1], result.From, result.To, scope, path.Name())
+	return nil
+}
+
+// printMoveOrder prints entries numbered as -path does, highlighting the
+// moved entry at the 1-based index moved.
+func printMoveOrder(entries []string, moved int) {
+	for i, p := range entries {
+		if i+1 == moved {
+			fmt.Printf("%s%3d  %s%s\n", color.Yellow, i+1, p, color.Reset)
+			continue
+		}
+		fmt.Printf("%s%3d%s  %s\n", color.Cyan, i+1, color.Reset, p)
+	}
+}
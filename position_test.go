@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestAddPosition(t *testing.T) {
+	setFlag(t, cmd.At, "2")
+	pos, err := addPosition()
+	if err != nil || pos.At != 2 {
+		t.Errorf("addPosition() = %+v, %v, want At 2", pos, err)
+	}
+	for _, at := range []string{"0", "second"} {
+		setFlag(t, cmd.At, at)
+		if _, err := addPosition(); err == nil {
+			t.Errorf("addPosition() with -at %s error = nil, want an error", at)
+		}
+	}
+}
+
+func TestMovePath(t *testing.T) {
+	store := useStore(t, env.EnvVar{Key: "Path", Value: `C:\a;C:\b;C:\c`, Type: env.TypeExpandString})
+	setFlag(t, cmd.MovePath, `C:\c`)
+
+	if err := movePath([]string{"1"}); err != nil {
+		t.Fatalf("movePath(1) error = %v", err)
+	}
+	if got := userValue(t, store, "Path"); got != `C:\c;C:\a;C:\b` {
+		t.Errorf("Path = %q after -mv C:\\c 1, want C:\\c;C:\\a;C:\\b", got)
+	}
+	if err := movePath([]string{"1"}); err != nil {
+		t.Fatalf("movePath(1) to the same place error = %v", err)
+	}
+
+	setFlag(t, cmd.Down, true)
+	if err := movePath(nil); err != nil {
+		t.Fatalf("movePath(-down) error = %v", err)
+	}
+	if got := userValue(t, store, "Path"); got != `C:\a;C:\c;C:\b` {
+		t.Errorf("Path = %q after -down, want C:\\a;C:\\c;C:\\b", got)
+	}
+	if err := movePath([]string{"1"}); err == nil {
+		t.Error("movePath(-down, 1) error = nil, want an error")
+	}
+	setFlag(t, cmd.Up, true)
+	if err := movePath(nil); err == nil {
+		t.Error("movePath(-up -down) error = nil, want an error")
+	}
+	setFlag(t, cmd.Up, false)
+	setFlag(t, cmd.Down, false)
+
+	for _, args := range [][]string{nil, {"first"}, {"9"}} {
+		if err := movePath(args); err == nil {
+			t.Errorf("movePath(%v) error = nil, want an error", args)
+		}
+	}
+}
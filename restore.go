@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/color"
+	"github.com/doraemonkeys/menv/dryrun"
+	"github.com/doraemonkeys/menv/env"
+	"github.com/doraemonkeys/menv/path"
+)
+
+// restoreEnvVars restores a backup file in the -mode given, after showing
+// what will be created, updated and deleted. Deletions are confirmed unless
+// -y is set. Exactly the changes shown are made; a variable changed in the
+// meantime fails the restore.
+func restoreEnvVars(filename string) error {
+	mode, err := env.ParseRestoreMode(*cmd.RestoreMode)
+	if err != nil {
+		return err
+	}
+	backup, err := env.LoadBackup(filename)
+	if err != nil {
+		return err
+	}
+
+	target := "user"
+	if *cmd.SetSystem {
+		target = "system"
+	}
+	color.Info("Restoring %d env vars from %s backup (created: %s) to %s, mode %s...",
+		len(backup.EnvVars), backup.Source, backup.CreatedAt.Format("2006-01-02 15:04:05"), target, mode)
+
+	c, err := env.RestoreChanges(backup, *cmd.SetSystem, mode)
+	if err != nil {
+		return err
+	}
+	plan, err := c.Plan()
+	if err != nil {
+		return err
+	}
+	var changes []env.ChangeResult
+	var summary env.ChangeSummary
+	for _, r := range plan {
+		summary.Add(r.Change)
+		if r.Change != env.Unchanged {
+			changes = append(changes, r)
+		}
+	}
+	if len(changes) == 0 {
+		color.Success("Nothing to restore, the %s env vars are up to date", target)
+		return nil
+	}
+
+	// A dry run prints the same changes once it is done.
+	if !*cmd.DryRun && !*cmd.CheckOnly {
+		color.Info("Preview: %s", summary)
+		dryrun.Write(os.Stdout, changes, path.Conventions())
+		fmt.Println()
+	}
+	if summary.Deleted > 0 && !*cmd.Yes {
+		if !confirmAction(fmt.Sprintf("Delete %d env var(s) that are not in the backup?", summary.Deleted)) {
+			color.Warning("Cancelled")
+			return nil
+		}
+	}
+
+	if _, err := env.ApplyChanges(env.PlannedChanges(changes)); err != nil {
+		return err
+	}
+	color.Done("Restored %d env vars: %s", summary.Changed(), summary)
+	return nil
+}
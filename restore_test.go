@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestRestoreEnvVars(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		yes     bool
+		input   string
+		b, c    string
+		wantErr bool
+	}{
+		{name: "merge", mode: "merge", b: "2", c: "3"},
+		{name: "replace confirmed", mode: "replace", input: "y\n", b: "2", c: "<unset>"},
+		{name: "replace cancelled", mode: "replace", input: "n\n", b: "changed", c: "3"},
+		{name: "replace with -y", mode: "replace", yes: true, b: "2", c: "<unset>"},
+		{name: "missing", mode: "missing", b: "changed", c: "3"},
+		{name: "invalid mode", mode: "all", b: "changed", c: "3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useStore(t, env.EnvVar{Key: "A", Value: "1"}, env.EnvVar{Key: "B", Value: "2"})
+			filename := filepath.Join(t.TempDir(), "backup.json")
+			if _, err := env.Backup(filename, false); err != nil {
+				t.Fatal(err)
+			}
+			// Since the backup, B changed and C was added.
+			store := useStore(t, env.EnvVar{Key: "A", Value: "1"}, env.EnvVar{Key: "B", Value: "changed"}, env.EnvVar{Key: "C", Value: "3"})
+			setFlag(t, cmd.RestoreMode, tt.mode)
+			setFlag(t, cmd.Yes, tt.yes)
+			useStdin(t, tt.input)
+
+			err := restoreEnvVars(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("restoreEnvVars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := userValue(t, store, "B"); got != tt.b {
+				t.Errorf("B = %q, want %q", got, tt.b)
+			}
+			if got := userValue(t, store, "C"); got != tt.c {
+				t.Errorf("C = %q, want %q", got, tt.c)
+			}
+		})
+	}
+}
+
+func TestRestoreEnvVars_UpToDate(t *testing.T) {
+	store := useStore(t, env.EnvVar{Key: "A", Value: "1"})
+	filename := filepath.Join(t.TempDir(), "backup.json")
+	if _, err := env.Backup(filename, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restoreEnvVars(filename); err != nil {
+		t.Fatalf("restoreEnvVars() error = %v", err)
+	}
+	if got := userValue(t, store, "A"); got != "1" {
+		t.Errorf("A = %q, want 1", got)
+	}
+	if err := restoreEnvVars(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("restoreEnvVars() of a missing file error = nil, want an error")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+	"github.com/doraemonkeys/menv/path"
+)
+
+// transferScope handles -promote and -demote: it moves an env var, or a
+// PATH entry with -path, between the user and system scopes.
+func transferScope(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected arguments: %v", args)
+	}
+	if *cmd.Promote != "" && *cmd.Demote != "" {
+		return errors.New("-promote and -demote cannot be combined")
+	}
+
+	from, to, key := env.ScopeUser, env.ScopeSystem, *cmd.Promote
+	if *cmd.Demote != "" {
+		from, to, key = env.ScopeSystem, env.ScopeUser, *cmd.Demote
+	}
+
+	if *cmd.ShowPath {
+		return path.Transfer(key, from, to, *cmd.Keep)
+	}
+	if from == env.ScopeUser {
+		return env.Promote(key, *cmd.Keep, *cmd.Force)
+	}
+	return env.Demote(key, *cmd.Keep, *cmd.Force)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/doraemonkeys/menv/cmd"
+	"github.com/doraemonkeys/menv/env"
+)
+
+func TestTransferScope(t *testing.T) {
+	store := useStore(t,
+		env.EnvVar{Key: "GOROOT", Value: `C:\go`},
+		env.EnvVar{Key: "Path", Value: `C:\bin;C:\tools`, Type: env.TypeExpandString},
+	)
+
+	setFlag(t, cmd.Promote, "GOROOT")
+	if err := transferScope(nil); err != nil {
+		t.Fatalf("transferScope(-promote GOROOT) error = %v", err)
+	}
+	if _, ok, _ := store.Get(env.ScopeSystem, "GOROOT"); !ok || userValue(t, store, "GOROOT") != "<unset>" {
+		t.Error("GOROOT was not moved to the system scope")
+	}
+	setFlag(t, cmd.Demote, "GOROOT")
+	if err := transferScope(nil); err == nil {
+		t.Error("transferScope(-promote -demote) error = nil, want an error")
+	}
+	setFlag(t, cmd.Promote, "")
+	if err := transferScope([]string{"x"}); err == nil {
+		t.Error("transferScope(x) error = nil, want an error")
+	}
+	if err := transferScope(nil); err != nil {
+		t.Fatalf("transferScope(-demote GOROOT) error = %v", err)
+	}
+	if got := userValue(t, store, "GOROOT"); got != `C:\go` {
+		t.Errorf("GOROOT = %q after -demote, want C:\\go", got)
+	}
+
+	setFlag(t, cmd.ShowPath, true)
+	setFlag(t, cmd.Demote, "")
+	setFlag(t, cmd.Promote, `C:\tools`)
+	if err := transferScope(nil); err != nil {
+		t.Fatalf("transferScope(-path -promote) error = %v", err)
+	}
+	if sys, _, _ := store.Get(env.ScopeSystem, "Path"); sys.Value != `C:\tools` {
+		t.Errorf("system Path = %q, want C:\\tools", sys.Value)
+	}
+	if got := userValue(t, store, "Path"); got != `C:\bin` {
+		t.Errorf("user Path = %q, want C:\\bin", got)
+	}
+}